@@ -0,0 +1,492 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	HTTP        HTTPConfig        `yaml:"http"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	UserService UserServiceConfig `yaml:"user_service"`
+	Database    DatabaseConfig    `yaml:"database"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	Ticket      TicketConfig      `yaml:"ticket"`
+	UserCache   UserCacheConfig   `yaml:"user_cache"`
+	Outbox      OutboxConfig      `yaml:"outbox"`
+	Webhook     WebhookConfig     `yaml:"webhook"`
+	Stream      StreamConfig      `yaml:"stream"`
+	Health      HealthConfig      `yaml:"health"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Log         LogConfig         `yaml:"log"`
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+type HTTPConfig struct {
+	Addr           string        `yaml:"addr"`
+	TLS            TLSConfig     `yaml:"tls"`
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+}
+
+type GRPCConfig struct {
+	Addr string    `yaml:"addr"`
+	TLS  TLSConfig `yaml:"tls"`
+}
+
+// MetricsConfig is the internal listener for /metrics. It is kept off the
+// public HTTP address because it has no authentication.
+type MetricsConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+// UserServiceConfig also tunes how calls are guarded: each attempt gets
+// CallTimeout, Unavailable errors are retried up to MaxRetries times, and
+// BreakerThreshold consecutive failures stop calls for BreakerCooldown.
+type UserServiceConfig struct {
+	Target           string          `yaml:"target"`
+	TLS              ClientTLSConfig `yaml:"tls"`
+	CallTimeout      time.Duration   `yaml:"call_timeout"`
+	MaxRetries       int             `yaml:"max_retries"`
+	RetryBackoff     time.Duration   `yaml:"retry_backoff"`
+	RetryMaxBackoff  time.Duration   `yaml:"retry_max_backoff"`
+	BreakerThreshold int             `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration   `yaml:"breaker_cooldown"`
+}
+
+// ClientTLSConfig configures the connection to the user service. With Enabled
+// false the connection is plaintext; CertFile and KeyFile are only needed for
+// mutual TLS.
+type ClientTLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+type DatabaseConfig struct {
+	User              string        `yaml:"user"`
+	Password          string        `yaml:"password"`
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	Name              string        `yaml:"name"`
+	MaxConns          int32         `yaml:"max_conns"`
+	MinConns          int32         `yaml:"min_conns"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period"`
+	AutoMigrate       bool          `yaml:"auto_migrate"`
+}
+
+type JWTConfig struct {
+	Algorithm     string `yaml:"algorithm"`
+	Secret        string `yaml:"secret"`
+	PublicKeyFile string `yaml:"public_key_file"`
+	JWKSFile      string `yaml:"jwks_file"`
+	Issuer        string `yaml:"issuer"`
+	Audience      string `yaml:"audience"`
+}
+
+type TicketConfig struct {
+	Policy       string        `yaml:"policy"`
+	WorkflowFile string        `yaml:"workflow_file"`
+	Retention    time.Duration `yaml:"retention"`
+}
+
+type UserCacheConfig struct {
+	Size        int           `yaml:"size"`
+	TTL         time.Duration `yaml:"ttl"`
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+}
+
+// OutboxConfig configures the relay. Events always feed the registered
+// webhooks; Publisher "webhook" additionally posts every event to WebhookURL
+// and "memory" queues it in process, up to MemoryBuffer events, where it is
+// logged. LeaseDuration is how long a claimed batch is reserved for one relay and
+// must cover publishing the whole batch.
+type OutboxConfig struct {
+	Publisher     string        `yaml:"publisher"`
+	WebhookURL    string        `yaml:"webhook_url"`
+	MemoryBuffer  int           `yaml:"memory_buffer"`
+	PollInterval  time.Duration `yaml:"poll_interval"`
+	BatchSize     int           `yaml:"batch_size"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+}
+
+type WebhookConfig struct {
+	PollInterval  time.Duration `yaml:"poll_interval"`
+	BatchSize     int           `yaml:"batch_size"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+}
+
+// StreamConfig sizes the ticket event stream: HistorySize events are kept for
+// resuming clients, and a subscriber more than SubscriberBuffer events behind
+// is disconnected.
+type StreamConfig struct {
+	HistorySize      int `yaml:"history_size"`
+	SubscriberBuffer int `yaml:"subscriber_buffer"`
+}
+
+type HealthConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// TracingConfig selects where spans go: "none", "stdout" for local testing or
+// "otlp". An empty OTLP endpoint falls back to the standard OTEL_EXPORTER_OTLP_*
+// variables.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter"`
+	ServiceName  string  `yaml:"service_name"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	OTLPInsecure bool    `yaml:"otlp_insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// SlogLevel parses Level, which Validate has already checked.
+func (c LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level))
+	return level
+}
+
+func Default() Config {
+	return Config{
+		HTTP:    HTTPConfig{Addr: ":3001", RequestTimeout: 30 * time.Second},
+		GRPC:    GRPCConfig{Addr: ":3002"},
+		Metrics: MetricsConfig{Addr: ":3003"},
+		UserService: UserServiceConfig{
+			Target:           ":9000",
+			CallTimeout:      2 * time.Second,
+			MaxRetries:       2,
+			RetryBackoff:     100 * time.Millisecond,
+			RetryMaxBackoff:  time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:              "localhost",
+			Port:              5432,
+			MaxConns:          10,
+			MinConns:          2,
+			MaxConnLifetime:   30 * time.Minute,
+			MaxConnIdleTime:   10 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			AutoMigrate:       true,
+		},
+		Ticket: TicketConfig{Retention: 30 * 24 * time.Hour},
+		UserCache: UserCacheConfig{
+			Size:        1000,
+			TTL:         5 * time.Minute,
+			NegativeTTL: 30 * time.Second,
+		},
+		Outbox: OutboxConfig{
+			Publisher:     "none",
+			MemoryBuffer:  1024,
+			PollInterval:  time.Second,
+			BatchSize:     100,
+			MaxAttempts:   16,
+			LeaseDuration: 5 * time.Minute,
+		},
+		Webhook: WebhookConfig{
+			PollInterval:  time.Second,
+			BatchSize:     50,
+			MaxAttempts:   8,
+			LeaseDuration: 5 * time.Minute,
+		},
+		Stream:          StreamConfig{HistorySize: 1024, SubscriberBuffer: 64},
+		Health:          HealthConfig{Timeout: 2 * time.Second},
+		ShutdownTimeout: 20 * time.Second,
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "vkrf-ticket",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{Level: "info"},
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file named
+// by path (if any), then environment variables, and validates the result.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	env := envLoader{}
+	env.string(&cfg.HTTP.Addr, "HTTP_ADDR")
+	env.string(&cfg.HTTP.TLS.CertFile, "HTTP_TLS_CERT_FILE")
+	env.string(&cfg.HTTP.TLS.KeyFile, "HTTP_TLS_KEY_FILE")
+	env.duration(&cfg.HTTP.RequestTimeout, "HTTP_REQUEST_TIMEOUT")
+
+	env.string(&cfg.GRPC.Addr, "GRPC_ADDR")
+	env.string(&cfg.GRPC.TLS.CertFile, "GRPC_TLS_CERT_FILE")
+	env.string(&cfg.GRPC.TLS.KeyFile, "GRPC_TLS_KEY_FILE")
+
+	env.string(&cfg.Metrics.Addr, "METRICS_ADDR")
+
+	env.string(&cfg.UserService.Target, "USER_SERVICE_TARGET")
+	env.bool(&cfg.UserService.TLS.Enabled, "USER_SERVICE_TLS")
+	env.string(&cfg.UserService.TLS.CAFile, "USER_SERVICE_TLS_CA_FILE")
+	env.string(&cfg.UserService.TLS.CertFile, "USER_SERVICE_TLS_CERT_FILE")
+	env.string(&cfg.UserService.TLS.KeyFile, "USER_SERVICE_TLS_KEY_FILE")
+	env.string(&cfg.UserService.TLS.ServerName, "USER_SERVICE_TLS_SERVER_NAME")
+	env.duration(&cfg.UserService.CallTimeout, "USER_SERVICE_CALL_TIMEOUT")
+	env.int(&cfg.UserService.MaxRetries, "USER_SERVICE_MAX_RETRIES")
+	env.duration(&cfg.UserService.RetryBackoff, "USER_SERVICE_RETRY_BACKOFF")
+	env.duration(&cfg.UserService.RetryMaxBackoff, "USER_SERVICE_RETRY_MAX_BACKOFF")
+	env.int(&cfg.UserService.BreakerThreshold, "USER_SERVICE_BREAKER_THRESHOLD")
+	env.duration(&cfg.UserService.BreakerCooldown, "USER_SERVICE_BREAKER_COOLDOWN")
+
+	env.string(&cfg.Database.User, "DB_USER")
+	env.string(&cfg.Database.Password, "DB_PASSWORD")
+	env.string(&cfg.Database.Host, "DB_HOST")
+	env.int(&cfg.Database.Port, "DB_PORT")
+	env.string(&cfg.Database.Name, "DB_NAME")
+	env.int32(&cfg.Database.MaxConns, "DB_MAX_CONNS")
+	env.int32(&cfg.Database.MinConns, "DB_MIN_CONNS")
+	env.duration(&cfg.Database.MaxConnLifetime, "DB_MAX_CONN_LIFETIME")
+	env.duration(&cfg.Database.MaxConnIdleTime, "DB_MAX_CONN_IDLE_TIME")
+	env.duration(&cfg.Database.HealthCheckPeriod, "DB_HEALTH_CHECK_PERIOD")
+	env.bool(&cfg.Database.AutoMigrate, "DB_AUTO_MIGRATE")
+
+	env.string(&cfg.JWT.Algorithm, "JWT_ALGORITHM")
+	env.string(&cfg.JWT.Secret, "JWT_SECRET")
+	env.string(&cfg.JWT.PublicKeyFile, "JWT_PUBLIC_KEY_FILE")
+	env.string(&cfg.JWT.JWKSFile, "JWT_JWKS_FILE")
+	env.string(&cfg.JWT.Issuer, "JWT_ISSUER")
+	env.string(&cfg.JWT.Audience, "JWT_AUDIENCE")
+
+	env.string(&cfg.Ticket.Policy, "TICKET_POLICY")
+	env.string(&cfg.Ticket.WorkflowFile, "WORKFLOW_FILE")
+	env.duration(&cfg.Ticket.Retention, "TICKET_RETENTION")
+
+	env.int(&cfg.UserCache.Size, "USER_CACHE_SIZE")
+	env.duration(&cfg.UserCache.TTL, "USER_CACHE_TTL")
+	env.duration(&cfg.UserCache.NegativeTTL, "USER_CACHE_NEGATIVE_TTL")
+
+	env.string(&cfg.Outbox.Publisher, "OUTBOX_PUBLISHER")
+	env.string(&cfg.Outbox.WebhookURL, "OUTBOX_WEBHOOK_URL")
+	env.int(&cfg.Outbox.MemoryBuffer, "OUTBOX_MEMORY_BUFFER")
+	env.duration(&cfg.Outbox.PollInterval, "OUTBOX_POLL_INTERVAL")
+	env.int(&cfg.Outbox.BatchSize, "OUTBOX_BATCH_SIZE")
+	env.int(&cfg.Outbox.MaxAttempts, "OUTBOX_MAX_ATTEMPTS")
+	env.duration(&cfg.Outbox.LeaseDuration, "OUTBOX_LEASE_DURATION")
+
+	env.duration(&cfg.Webhook.PollInterval, "WEBHOOK_POLL_INTERVAL")
+	env.int(&cfg.Webhook.BatchSize, "WEBHOOK_BATCH_SIZE")
+	env.int(&cfg.Webhook.MaxAttempts, "WEBHOOK_MAX_ATTEMPTS")
+	env.duration(&cfg.Webhook.LeaseDuration, "WEBHOOK_LEASE_DURATION")
+
+	env.int(&cfg.Stream.HistorySize, "STREAM_HISTORY_SIZE")
+	env.int(&cfg.Stream.SubscriberBuffer, "STREAM_SUBSCRIBER_BUFFER")
+
+	env.duration(&cfg.Health.Timeout, "HEALTH_CHECK_TIMEOUT")
+
+	env.string(&cfg.Tracing.Exporter, "TRACING_EXPORTER")
+	env.string(&cfg.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	env.string(&cfg.Tracing.OTLPEndpoint, "TRACING_OTLP_ENDPOINT")
+	env.bool(&cfg.Tracing.OTLPInsecure, "TRACING_OTLP_INSECURE")
+	env.float64(&cfg.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO")
+
+	env.string(&cfg.Log.Level, "LOG_LEVEL")
+
+	env.duration(&cfg.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+
+	if err := errors.Join(env.errs...); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.HTTP.Addr != "", "http.addr is required")
+	check((c.HTTP.TLS.CertFile == "") == (c.HTTP.TLS.KeyFile == ""), "http.tls needs both cert_file and key_file")
+	check(c.HTTP.RequestTimeout > 0, "http.request_timeout must be positive")
+	check(c.GRPC.Addr != "", "grpc.addr is required")
+	check((c.GRPC.TLS.CertFile == "") == (c.GRPC.TLS.KeyFile == ""), "grpc.tls needs both cert_file and key_file")
+	check(c.Metrics.Addr != "", "metrics.addr is required")
+	check(c.Metrics.Addr != c.HTTP.Addr, "metrics.addr must differ from http.addr")
+	check(c.UserService.Target != "", "user_service.target is required")
+	check((c.UserService.TLS.CertFile == "") == (c.UserService.TLS.KeyFile == ""),
+		"user_service.tls needs both cert_file and key_file")
+	check(c.UserService.CallTimeout > 0, "user_service.call_timeout must be positive")
+	check(c.UserService.MaxRetries >= 0, "user_service.max_retries must not be negative")
+	check(c.UserService.RetryBackoff > 0, "user_service.retry_backoff must be positive")
+	check(c.UserService.RetryMaxBackoff >= c.UserService.RetryBackoff,
+		"user_service.retry_max_backoff must not be less than retry_backoff")
+	check(c.UserService.BreakerThreshold > 0, "user_service.breaker_threshold must be positive")
+	check(c.UserService.BreakerCooldown > 0, "user_service.breaker_cooldown must be positive")
+
+	check(c.Database.Host != "", "database.host is required")
+	check(c.Database.Port > 0 && c.Database.Port <= 65535, "database.port must be between 1 and 65535")
+	check(c.Database.Name != "", "database.name is required")
+	check(c.Database.MaxConns > 0, "database.max_conns must be positive")
+	check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns,
+		"database.min_conns must be between 0 and max_conns")
+	check(c.Database.MaxConnLifetime > 0, "database.max_conn_lifetime must be positive")
+	check(c.Database.MaxConnIdleTime > 0, "database.max_conn_idle_time must be positive")
+	check(c.Database.HealthCheckPeriod > 0, "database.health_check_period must be positive")
+
+	check(c.Ticket.Retention > 0, "ticket.retention must be positive")
+	check(c.UserCache.Size > 0, "user_cache.size must be positive")
+	check(c.UserCache.TTL > 0, "user_cache.ttl must be positive")
+	check(c.UserCache.NegativeTTL > 0, "user_cache.negative_ttl must be positive")
+
+	switch c.Outbox.Publisher {
+	case "none":
+	case "webhook":
+		check(c.Outbox.WebhookURL != "", "outbox.webhook_url is required for the webhook publisher")
+	case "memory":
+		check(c.Outbox.MemoryBuffer > 0, "outbox.memory_buffer must be positive for the memory publisher")
+	default:
+		check(false, "unknown outbox.publisher %q", c.Outbox.Publisher)
+	}
+	check(c.Outbox.PollInterval > 0, "outbox.poll_interval must be positive")
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+	check(c.Outbox.MaxAttempts > 0, "outbox.max_attempts must be positive")
+	check(c.Outbox.LeaseDuration > 0, "outbox.lease_duration must be positive")
+	check(c.Webhook.PollInterval > 0, "webhook.poll_interval must be positive")
+	check(c.Webhook.BatchSize > 0, "webhook.batch_size must be positive")
+	check(c.Webhook.MaxAttempts > 0, "webhook.max_attempts must be positive")
+	check(c.Webhook.LeaseDuration > 0, "webhook.lease_duration must be positive")
+	check(c.Stream.HistorySize > 0, "stream.history_size must be positive")
+	check(c.Stream.SubscriberBuffer > 0, "stream.subscriber_buffer must be positive")
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "unknown tracing.exporter %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "unknown log.level %q", c.Log.Level)
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+
+	return errors.Join(errs...)
+}
+
+// DatabaseURL is the connection string for pgxpool.ParseConfig. Credentials
+// and the database name are escaped, so they may contain any character.
+func (c DatabaseConfig) DatabaseURL() string {
+	u := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:   "/" + c.Name,
+	}
+
+	return u.String()
+}
+
+// envLoader applies set environment variables over the loaded values and
+// collects parse errors so they can all be reported at once.
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) string(target *string, key string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*target = value
+	}
+}
+
+func (l *envLoader) int(target *int, key string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not an integer", key, value))
+			return
+		}
+		*target = n
+	}
+}
+
+func (l *envLoader) int32(target *int32, key string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not an integer", key, value))
+			return
+		}
+		*target = int32(n)
+	}
+}
+
+func (l *envLoader) bool(target *bool, key string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, value))
+			return
+		}
+		*target = b
+	}
+}
+
+func (l *envLoader) float64(target *float64, key string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a number", key, value))
+			return
+		}
+		*target = f
+	}
+}
+
+func (l *envLoader) duration(target *time.Duration, key string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a duration", key, value))
+			return
+		}
+		*target = d
+	}
+}
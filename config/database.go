@@ -3,32 +3,32 @@ package config
 import (
 	"context"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"log"
 )
 
-func InitConnPool() *pgxpool.Pool {
-	connString := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", DBUser(), DBPassword(), DBHost(), DBPort(), DBName())
-
-	dbConfig, err := pgxpool.ParseConfig(connString)
+func InitConnPool(cfg DatabaseConfig, tracer pgx.QueryTracer) (*pgxpool.Pool, error) {
+	dbConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL())
 	if err != nil {
-		log.Fatalf("Unable to parse config: %v\n", err)
+		return nil, fmt.Errorf("unable to parse config: %w", err)
 	}
-	dbConfig.MaxConns = 10
-	dbConfig.MinConns = 2
-	dbConfig.MaxConnLifetime = 30 * 60 * 1000
-	dbConfig.MaxConnIdleTime = 10 * 60 * 1000
+	dbConfig.MaxConns = cfg.MaxConns
+	dbConfig.MinConns = cfg.MinConns
+	dbConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	dbConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	dbConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	dbConfig.ConnConfig.Tracer = tracer
 
 	poolConfig, err := pgxpool.NewWithConfig(context.Background(), dbConfig)
 	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v\n", err)
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
 	}
 
 	err = poolConfig.Ping(context.Background())
 	if err != nil {
-		log.Fatalf("Unable to ping database: %v\n", err)
+		poolConfig.Close()
+		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
 
-	return poolConfig
-
+	return poolConfig, nil
 }
@@ -0,0 +1,159 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrCursorMismatch = errors.New("cursor was issued for a different sort, order or filter")
+)
+
+// cursorPayload records the listing a cursor was issued for next to the
+// position, so it can't be replayed against a different query.
+type cursorPayload struct {
+	Sort   string `json:"s"`
+	Order  string `json:"o,omitempty"`
+	Filter string `json:"f,omitempty"`
+	Value  string `json:"v"`
+	Id     string `json:"id"`
+}
+
+func EncodeTicketCursor(filter model.TicketFilter, ticket model.Ticket) string {
+	payload := ticketCursorPayload(filter)
+	switch filter.Sort {
+	case "updated_at":
+		payload.Value = ticket.UpdatedAt.Format(time.RFC3339Nano)
+	case "point":
+		payload.Value = strconv.Itoa(ticket.Point)
+	default:
+		payload.Value = ticket.CreatedAt.Format(time.RFC3339Nano)
+	}
+	payload.Id = ticket.Id.String()
+
+	return encodeCursor(payload)
+}
+
+func DecodeTicketCursor(filter model.TicketFilter, cursor string) (*model.TicketCursor, error) {
+	value, id, err := decodeCursor(ticketCursorPayload(filter), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var typed interface{}
+	switch filter.Sort {
+	case "point":
+		typed, err = strconv.Atoi(value)
+	default:
+		typed, err = time.Parse(time.RFC3339Nano, value)
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &model.TicketCursor{Value: typed, Id: id}, nil
+}
+
+func EncodeCommentCursor(comment model.Comment) string {
+	payload := commentCursorPayload(comment.TicketId.String())
+	payload.Value = comment.CreatedAt.Format(time.RFC3339Nano)
+	payload.Id = comment.Id.String()
+
+	return encodeCursor(payload)
+}
+
+func DecodeCommentCursor(ticketId, cursor string) (*model.CommentCursor, error) {
+	value, id, err := decodeCursor(commentCursorPayload(ticketId), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &model.CommentCursor{CreatedAt: createdAt, Id: id}, nil
+}
+
+func ticketCursorPayload(filter model.TicketFilter) cursorPayload {
+	return cursorPayload{Sort: filter.Sort, Order: filter.Order, Filter: ticketFilterHash(filter)}
+}
+
+// commentCursorPayload binds a comment cursor to the ticket whose comments it
+// pages through.
+func commentCursorPayload(ticketId string) cursorPayload {
+	if id, err := uuid.Parse(ticketId); err == nil {
+		ticketId = id.String()
+	}
+	return cursorPayload{Sort: "comment", Filter: ticketId}
+}
+
+// ticketFilterHash identifies the set of tickets a listing pages through. The
+// page size isn't part of it, so a client may change the limit between pages.
+func ticketFilterHash(filter model.TicketFilter) string {
+	statuses := slices.Clone(filter.Statuses)
+	slices.Sort(statuses)
+
+	data, _ := json.Marshal(struct {
+		Statuses    []string
+		AssigneeId  *uuid.UUID
+		MinPoint    *int
+		MaxPoint    *int
+		CreatedFrom *time.Time
+		CreatedTo   *time.Time
+		UpdatedFrom *time.Time
+		UpdatedTo   *time.Time
+		Title       string
+	}{statuses, filter.AssigneeId, filter.MinPoint, filter.MaxPoint, utc(filter.CreatedFrom),
+		utc(filter.CreatedTo), utc(filter.UpdatedFrom), utc(filter.UpdatedTo), filter.Title})
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func encodeCursor(payload cursorPayload) string {
+	data, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the position stored in cursor after checking it was
+// issued for the listing described by want.
+func decodeCursor(want cursorPayload, cursor string) (string, uuid.UUID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", uuid.Nil, ErrInvalidCursor
+	}
+
+	payload := cursorPayload{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return "", uuid.Nil, ErrInvalidCursor
+	}
+	if payload.Sort != want.Sort || payload.Order != want.Order || payload.Filter != want.Filter {
+		return "", uuid.Nil, ErrCursorMismatch
+	}
+
+	id, err := uuid.Parse(payload.Id)
+	if err != nil {
+		return "", uuid.Nil, ErrInvalidCursor
+	}
+
+	return payload.Value, id, nil
+}
@@ -3,34 +3,88 @@ package helper
 import (
 	"context"
 	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
-	"google.golang.org/grpc"
-	"log"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"sync"
 )
 
-func GetUserByEmailGrpc(conn *grpc.ClientConn, email string) (*grpcserver.GetUserByEmailResponse, error) {
-	c := grpcserver.NewUserServiceClient(conn)
+func GetUserByEmailGrpc(ctx context.Context, client grpcserver.UserServiceClient, email string) (*grpcserver.GetUserByEmailResponse, error) {
 	userRequest := grpcserver.GetUserByEmailRequest{
 		Email: email,
 	}
-	resp, err := c.GetUserByEmail(context.Background(), &userRequest)
+	resp, err := client.GetUserByEmail(ctx, &userRequest)
 	if err != nil {
-		log.Printf("Error: %v", err)
+		slog.ErrorContext(ctx, "User service call failed", "method", "GetUserByEmail", "error", err)
 		return nil, err
 	}
 
 	return resp, nil
 }
 
-func GetUserByUserIdGrpc(conn *grpc.ClientConn, userId string) (*grpcserver.GetUserByUserIdResponse, error) {
-	c := grpcserver.NewUserServiceClient(conn)
+func GetUserByUserIdGrpc(ctx context.Context, client grpcserver.UserServiceClient, userId string) (*grpcserver.GetUserByUserIdResponse, error) {
 	userRequest := grpcserver.GetUserByUserIdRequest{
 		UserId: userId,
 	}
-	resp, err := c.GetUserByUserId(context.Background(), &userRequest)
+	resp, err := client.GetUserByUserId(ctx, &userRequest)
 	if err != nil {
-		log.Printf("Error: %v", err)
+		slog.ErrorContext(ctx, "User service call failed", "method", "GetUserByUserId", "error", err)
 		return nil, err
 	}
 
 	return resp, nil
 }
+
+const userLookupConcurrency = 8
+
+// GetUsersByIdsGrpc resolves users in one GetUsersByIds call. Against a user
+// service that doesn't implement it yet, it falls back to GetUserByUserId
+// calls with bounded concurrency.
+func GetUsersByIdsGrpc(ctx context.Context, client grpcserver.UserServiceClient, userIds []string) (map[string]*grpcserver.UserProto, error) {
+	users := make(map[string]*grpcserver.UserProto, len(userIds))
+	if len(userIds) == 0 {
+		return users, nil
+	}
+
+	userRequest := grpcserver.GetUsersByIdsRequest{
+		UserIds: userIds,
+	}
+	resp, err := client.GetUsersByIds(ctx, &userRequest)
+	if err == nil {
+		for _, user := range resp.Users {
+			users[user.Id] = user
+		}
+		return users, nil
+	}
+	if status.Code(err) != codes.Unimplemented {
+		slog.ErrorContext(ctx, "User service call failed", "method", "GetUsersByIds", "error", err)
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(userLookupConcurrency)
+	for _, userId := range userIds {
+		userId := userId
+		g.Go(func() error {
+			resp, err := GetUserByUserIdGrpc(gctx, client, userId)
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			users[userId] = resp.User
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,206 @@
+package helper
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenMissing = errors.New("missing bearer token")
+	ErrTokenExpired = errors.New("token is expired")
+	ErrTokenInvalid = errors.New("token is invalid")
+)
+
+type JWTConfig struct {
+	Algorithm     string
+	Secret        string
+	PublicKeyFile string
+	JWKSFile      string
+	Issuer        string
+	Audience      string
+}
+
+type JWTVerifier struct {
+	algorithm string
+	secret    []byte
+	publicKey *rsa.PublicKey
+	keys      map[string]*rsa.PublicKey
+	parser    *jwt.Parser
+}
+
+type principalClaims struct {
+	Email string   `json:"email"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = jwt.SigningMethodHS256.Alg()
+	}
+
+	v := &JWTVerifier{algorithm: cfg.Algorithm}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if cfg.Secret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		v.secret = []byte(cfg.Secret)
+	case jwt.SigningMethodRS256.Alg():
+		publicKey, keys, err := loadRSAKeys(cfg.PublicKeyFile, cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKey = publicKey
+		v.keys = keys
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+func (v *JWTVerifier) Verify(tokenString string) (model.Principal, error) {
+	if tokenString == "" {
+		return model.Principal{}, ErrTokenMissing
+	}
+
+	claims := principalClaims{}
+	_, err := v.parser.ParseWithClaims(tokenString, &claims, v.keyFunc)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return model.Principal{}, ErrTokenExpired
+		}
+		return model.Principal{}, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+
+	if claims.Email == "" {
+		return model.Principal{}, fmt.Errorf("%w: email claim is required", ErrTokenInvalid)
+	}
+
+	principal := model.Principal{
+		UserId: claims.Subject,
+		Email:  claims.Email,
+		Name:   claims.Name,
+		Roles:  claims.Roles,
+	}
+
+	return principal, nil
+}
+
+// keyFunc picks the RS256 key by the token's kid: a JWKS key with that kid
+// wins, and any token without a match (including one with no kid) falls back
+// to the static PEM key. Without a static key such tokens are rejected.
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	if v.secret != nil {
+		return v.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if v.publicKey != nil {
+		return v.publicKey, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func loadRSAKeys(publicKeyFile, jwksFile string) (*rsa.PublicKey, map[string]*rsa.PublicKey, error) {
+	if publicKeyFile == "" && jwksFile == "" {
+		return nil, nil, errors.New("JWT_PUBLIC_KEY_FILE or JWT_JWKS_FILE is required for RS256")
+	}
+
+	var publicKey *rsa.PublicKey
+	if publicKeyFile != "" {
+		data, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var keys map[string]*rsa.PublicKey
+	if jwksFile != "" {
+		data, err := os.ReadFile(jwksFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys, err = parseJWKS(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if publicKey == nil && len(keys) == 0 {
+		return nil, nil, errors.New("no RSA signing keys found in JWT_PUBLIC_KEY_FILE or JWT_JWKS_FILE")
+	}
+
+	return publicKey, keys, nil
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS modulus for key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS exponent for key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,111 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type Kind string
+
+const (
+	KindInvalidArgument      Kind = "invalid_argument"
+	KindUnauthenticated      Kind = "unauthenticated"
+	KindForbidden            Kind = "forbidden"
+	KindNotFound             Kind = "not_found"
+	KindConflict             Kind = "conflict"
+	KindPreconditionFailed   Kind = "precondition_failed"
+	KindPreconditionRequired Kind = "precondition_required"
+	KindUnprocessable        Kind = "unprocessable"
+	KindUpstream             Kind = "upstream"
+	KindDeadlineExceeded     Kind = "deadline_exceeded"
+	KindCanceled             Kind = "canceled"
+	KindInternal             Kind = "internal"
+)
+
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func InvalidArgument(message string, err error) *Error {
+	return New(KindInvalidArgument, message, err)
+}
+
+func Unauthenticated(message string, err error) *Error {
+	return New(KindUnauthenticated, message, err)
+}
+
+func Forbidden(message string, err error) *Error {
+	return New(KindForbidden, message, err)
+}
+
+func NotFound(message string, err error) *Error {
+	return New(KindNotFound, message, err)
+}
+
+func Conflict(message string, err error) *Error {
+	return New(KindConflict, message, err)
+}
+
+func PreconditionFailed(message string, err error) *Error {
+	return New(KindPreconditionFailed, message, err)
+}
+
+func PreconditionRequired(message string, err error) *Error {
+	return New(KindPreconditionRequired, message, err)
+}
+
+func Unprocessable(message string, err error) *Error {
+	return New(KindUnprocessable, message, err)
+}
+
+func Upstream(message string, err error) *Error {
+	return New(KindUpstream, message, err)
+}
+
+func DeadlineExceeded(message string, err error) *Error {
+	return New(KindDeadlineExceeded, message, err)
+}
+
+func Canceled(message string, err error) *Error {
+	return New(KindCanceled, message, err)
+}
+
+// FromContext reports a context deadline or cancellation anywhere in err's
+// chain as the matching kind, so a timed out query isn't mistaken for an
+// internal error. Other errors are returned unchanged.
+func FromContext(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded("request timed out", err)
+	case errors.Is(err, context.Canceled):
+		return Canceled("request was canceled", err)
+	}
+	return err
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or KindInternal.
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	return KindInternal
+}
@@ -0,0 +1,179 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidStreamId = errors.New("invalid stream event id")
+
+// StreamEvent is a ticket event together with the ticket state subscribers
+// filter on. Seq increases by one per published event. StreamId combines it
+// with the broker's epoch and is what clients send back as Last-Event-ID.
+type StreamEvent struct {
+	Seq      uint64 `json:"-"`
+	StreamId string `json:"-"`
+	model.Event
+	AssigneeId string `json:"assignee_id"`
+	Status     string `json:"status"`
+}
+
+type Subscription struct {
+	Events <-chan StreamEvent
+	// Missed is true when the requested resume point has already been evicted
+	// from the history, so some events between it and Backlog are lost.
+	Missed  bool
+	Backlog []StreamEvent
+}
+
+// Broker fans ticket events out to in-process subscribers and keeps the last
+// few events so reconnecting clients can resume. A subscriber that falls
+// behind is disconnected instead of blocking publishers; it can reconnect and
+// resume from the history.
+//
+// Sequence numbers restart with the process, so every broker picks a random
+// epoch and ids from another epoch are never resumed from.
+type Broker struct {
+	epoch       string
+	mu          sync.Mutex
+	seq         uint64
+	history     []StreamEvent
+	historySize int
+	buffer      int
+	subscribers map[chan StreamEvent]struct{}
+	closed      bool
+}
+
+func New(historySize, buffer int) *Broker {
+	if historySize <= 0 {
+		historySize = 1024
+	}
+	if buffer <= 0 {
+		buffer = 64
+	}
+	return &Broker{
+		epoch:       newEpoch(),
+		historySize: historySize,
+		buffer:      buffer,
+		subscribers: make(map[chan StreamEvent]struct{}),
+	}
+}
+
+func (b *Broker) Publish(event model.Event, assigneeId, status string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	se := StreamEvent{
+		Seq:        b.seq,
+		StreamId:   b.epoch + "-" + strconv.FormatUint(b.seq, 10),
+		Event:      event,
+		AssigneeId: assigneeId,
+		Status:     status,
+	}
+
+	b.history = append(b.history, se)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- se:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe registers a subscriber. When lastStreamId is set the events
+// published after it that are still in the history are returned as Backlog.
+func (b *Broker) Subscribe(lastStreamId string) (Subscription, func(), error) {
+	var epoch string
+	var lastSeq uint64
+	if lastStreamId != "" {
+		var err error
+		if epoch, lastSeq, err = parseStreamId(lastStreamId); err != nil {
+			return Subscription{}, nil, err
+		}
+	}
+
+	ch := make(chan StreamEvent, b.buffer)
+
+	b.mu.Lock()
+	sub := Subscription{Events: ch}
+	switch {
+	case lastStreamId != "" && epoch != b.epoch, lastSeq > b.seq:
+		// The id comes from another process, e.g. before a restart; nothing
+		// can be replayed.
+		sub.Missed = true
+	case lastSeq > 0 && lastSeq < b.seq:
+		for _, se := range b.history {
+			if se.Seq > lastSeq {
+				sub.Backlog = append(sub.Backlog, se)
+			}
+		}
+		sub.Missed = len(sub.Backlog) == 0 || sub.Backlog[0].Seq != lastSeq+1
+	}
+	if b.closed {
+		close(ch)
+	} else {
+		b.subscribers[ch] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return sub, unsubscribe, nil
+}
+
+// Close disconnects every subscriber and rejects new ones so long-lived
+// streams end when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func newEpoch() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("broker: generate epoch: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// parseStreamId splits an id into its epoch and sequence number. Ids from
+// before epochs existed are bare numbers and get an empty epoch.
+func parseStreamId(id string) (string, uint64, error) {
+	epoch, seq := "", id
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		epoch, seq = id[:i], id[i+1:]
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidStreamId
+	}
+
+	return epoch, n, nil
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/service"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type commentController struct {
+	commentService service.CommentService
+	validate       *validator.Validate
+}
+
+type CommentController interface {
+	CreateComment(ctx *fiber.Ctx) error
+	GetComments(ctx *fiber.Ctx) error
+	UpdateComment(ctx *fiber.Ctx) error
+	DeleteComment(ctx *fiber.Ctx) error
+}
+
+func NewCommentController(commentService service.CommentService, validate *validator.Validate) CommentController {
+	return &commentController{commentService: commentService, validate: validate}
+}
+
+func (c *commentController) CreateComment(ctx *fiber.Ctx) error {
+	ticketId := ctx.Params("ticketId")
+	principal := ctx.Locals("principal").(model.Principal)
+	comment := model.CommentRequest{}
+	if err := ctx.BodyParser(&comment); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(comment); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	commentResponse, err := c.commentService.CreateComment(ctx.UserContext(), ticketId, principal, comment)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Comment created",
+		"status":  fiber.StatusCreated,
+		"data":    commentResponse,
+	})
+}
+
+func (c *commentController) GetComments(ctx *fiber.Ctx) error {
+	ticketId := ctx.Params("ticketId")
+	query := model.CommentQuery{}
+	if err := ctx.QueryParser(&query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+
+	var cursor *model.CommentCursor
+	if query.Cursor != "" {
+		var err error
+		cursor, err = helper.DecodeCommentCursor(ticketId, query.Cursor)
+		if err != nil {
+			return apperror.InvalidArgument("Invalid request", err)
+		}
+	}
+
+	comments, nextCursor, err := c.commentService.GetComments(ctx.UserContext(), ticketId, query.Limit, cursor)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":     "Success",
+		"status":      fiber.StatusOK,
+		"data":        comments,
+		"next_cursor": nextCursor,
+	})
+}
+
+func (c *commentController) UpdateComment(ctx *fiber.Ctx) error {
+	ticketId := ctx.Params("ticketId")
+	commentId := ctx.Params("commentId")
+	principal := ctx.Locals("principal").(model.Principal)
+	comment := model.CommentRequest{}
+	if err := ctx.BodyParser(&comment); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(comment); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	commentResponse, err := c.commentService.UpdateComment(ctx.UserContext(), ticketId, commentId, principal, comment)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Comment updated",
+		"status":  fiber.StatusOK,
+		"data":    commentResponse,
+	})
+}
+
+func (c *commentController) DeleteComment(ctx *fiber.Ctx) error {
+	ticketId := ctx.Params("ticketId")
+	commentId := ctx.Params("commentId")
+	principal := ctx.Locals("principal").(model.Principal)
+
+	if err := c.commentService.DeleteComment(ctx.UserContext(), ticketId, commentId, principal); err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Comment deleted",
+		"status":  fiber.StatusOK,
+	})
+}
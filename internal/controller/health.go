@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"github.com/gemm123/vkrf-ticket/internal/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+type healthController struct {
+	checker *health.Checker
+}
+
+type HealthController interface {
+	Liveness(ctx *fiber.Ctx) error
+	Readiness(ctx *fiber.Ctx) error
+}
+
+func NewHealthController(checker *health.Checker) HealthController {
+	return &healthController{checker: checker}
+}
+
+func (c *healthController) Liveness(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(health.Report{Status: health.StatusUp})
+}
+
+func (c *healthController) Readiness(ctx *fiber.Ctx) error {
+	report := c.checker.Check(ctx.UserContext())
+
+	status := fiber.StatusOK
+	if report.Status == health.StatusDown {
+		status = fiber.StatusServiceUnavailable
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Status(status).JSON(report)
+}
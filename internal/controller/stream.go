@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/broker"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"time"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	streamRetry     = 3 * time.Second
+)
+
+type streamController struct {
+	broker   *broker.Broker
+	validate *validator.Validate
+}
+
+type StreamController interface {
+	StreamTickets(ctx *fiber.Ctx) error
+}
+
+func NewStreamController(broker *broker.Broker, validate *validator.Validate) StreamController {
+	return &streamController{broker: broker, validate: validate}
+}
+
+func (c *streamController) StreamTickets(ctx *fiber.Ctx) error {
+	query := model.TicketStreamQuery{}
+	if err := ctx.QueryParser(&query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+	if err := c.validate.Struct(query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	lastEventId := strings.TrimSpace(ctx.Get("Last-Event-ID"))
+	if lastEventId == "" {
+		lastEventId = query.LastEventId
+	}
+
+	statuses := make(map[string]bool)
+	for _, status := range strings.Split(query.Status, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses[status] = true
+		}
+	}
+	matches := func(se broker.StreamEvent) bool {
+		if query.Assignee != "" && !strings.EqualFold(se.AssigneeId, query.Assignee) {
+			return false
+		}
+		return len(statuses) == 0 || statuses[se.Status]
+	}
+
+	sub, unsubscribe, err := c.broker.Subscribe(lastEventId)
+	if err != nil {
+		return apperror.InvalidArgument("Last-Event-ID must be an event id from this stream", err)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		// Writing straight away makes the server send the headers, so clients
+		// see the stream open even when there is nothing to replay.
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		if sub.Missed {
+			fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+		}
+		for _, se := range sub.Backlog {
+			if matches(se) && writeStreamEvent(w, se) != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case se, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				if !matches(se) {
+					continue
+				}
+				if err := writeStreamEvent(w, se); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+
+			// A failed flush means the client went away.
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeStreamEvent(w *bufio.Writer, se broker.StreamEvent) error {
+	data, err := json.Marshal(se)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", se.StreamId, se.Type, data)
+	return err
+}
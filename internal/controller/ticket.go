@@ -1,10 +1,16 @@
 package controller
 
 import (
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
 	"github.com/gemm123/vkrf-ticket/internal/model"
 	"github.com/gemm123/vkrf-ticket/internal/service"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ticketController struct {
@@ -21,6 +27,9 @@ type TicketController interface {
 	UpdateStatusTicket(ctx *fiber.Ctx) error
 	Summary(ctx *fiber.Ctx) error
 	Performance(ctx *fiber.Ctx) error
+	DeleteTicket(ctx *fiber.Ctx) error
+	RestoreTicket(ctx *fiber.Ctx) error
+	PurgeDeletedTickets(ctx *fiber.Ctx) error
 }
 
 func NewTicketController(ticketService service.TicketService, validate *validator.Validate) TicketController {
@@ -30,65 +39,65 @@ func NewTicketController(ticketService service.TicketService, validate *validato
 func (c *ticketController) CreateTicket(ctx *fiber.Ctx) error {
 	ticket := model.TicketRequest{}
 	if err := ctx.BodyParser(&ticket); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 	err := c.validate.Struct(ticket)
 	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
-	email := ctx.Locals("email").(string)
+	principal := ctx.Locals("principal").(model.Principal)
 
-	if err := c.ticketService.CreateTicket(ticket, email); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to create ticket",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+	ticketId, err := c.ticketService.CreateTicket(ctx.UserContext(), ticket, principal.Email)
+	if err != nil {
+		return err
 	}
 
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Ticket created",
 		"status":  fiber.StatusCreated,
+		"data": fiber.Map{
+			"id": ticketId,
+		},
 	})
 }
 
 func (c *ticketController) GetAllTicket(ctx *fiber.Ctx) error {
-	tickets, err := c.ticketService.GetAllTicket()
+	query := model.TicketQuery{}
+	if err := ctx.QueryParser(&query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	filter, err := ticketFilterFromQuery(query)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to get all tickets",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	tickets, nextCursor, err := c.ticketService.GetAllTicket(ctx.UserContext(), filter)
+	if err != nil {
+		return err
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Success",
-		"status":  fiber.StatusOK,
-		"data":    tickets,
+		"message":     "Success",
+		"status":      fiber.StatusOK,
+		"data":        tickets,
+		"next_cursor": nextCursor,
 	})
 }
 
 func (c *ticketController) GetDetailTicket(ctx *fiber.Ctx) error {
 	ticketId := ctx.Params("ticketId")
-	detailTicket, err := c.ticketService.GetDetailTicket(ticketId)
+	detailTicket, err := c.ticketService.GetDetailTicket(ctx.UserContext(), ticketId)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to get detail ticket",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, versionETag(detailTicket.Version))
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Success",
 		"status":  fiber.StatusOK,
@@ -98,33 +107,28 @@ func (c *ticketController) GetDetailTicket(ctx *fiber.Ctx) error {
 
 func (c *ticketController) UpdateUserTicket(ctx *fiber.Ctx) error {
 	ticketId := ctx.Params("ticketId")
-	email := ctx.Locals("email").(string)
+	principal := ctx.Locals("principal").(model.Principal)
+	match, err := ifMatchVersions(ctx)
+	if err != nil {
+		return err
+	}
 	var jsonData map[string]interface{}
 	if err := ctx.BodyParser(&jsonData); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
-	err := c.validate.Var(jsonData["email"], "required,email")
+	emailAssignee, _ := jsonData["email"].(string)
+	err = c.validate.Var(emailAssignee, "required,email")
 	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
-	if err := c.ticketService.UpdateUserTicket(jsonData["email"].(string), ticketId, email); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update ticket",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+	version, err := c.ticketService.UpdateUserTicket(ctx.UserContext(), emailAssignee, ticketId, match, principal)
+	if err != nil {
+		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, versionETag(version))
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Ticket updated",
 		"status":  fiber.StatusOK,
@@ -133,32 +137,26 @@ func (c *ticketController) UpdateUserTicket(ctx *fiber.Ctx) error {
 
 func (c *ticketController) UpdateEditTicket(ctx *fiber.Ctx) error {
 	ticketId := ctx.Params("ticketId")
-	email := ctx.Locals("email").(string)
+	principal := ctx.Locals("principal").(model.Principal)
+	match, err := ifMatchVersions(ctx)
+	if err != nil {
+		return err
+	}
 	editTicket := model.EditTicketRequest{}
 	if err := ctx.BodyParser(&editTicket); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
 	if err := c.validate.Struct(editTicket); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
-	if err := c.ticketService.UpdateEditTicket(ticketId, email, editTicket); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update ticket",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+	version, err := c.ticketService.UpdateEditTicket(ctx.UserContext(), ticketId, match, principal, editTicket)
+	if err != nil {
+		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, versionETag(version))
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Ticket updated",
 		"status":  fiber.StatusOK,
@@ -167,33 +165,28 @@ func (c *ticketController) UpdateEditTicket(ctx *fiber.Ctx) error {
 
 func (c *ticketController) UpdateStatusTicket(ctx *fiber.Ctx) error {
 	ticketId := ctx.Params("ticketId")
-	email := ctx.Locals("email").(string)
+	principal := ctx.Locals("principal").(model.Principal)
+	match, err := ifMatchVersions(ctx)
+	if err != nil {
+		return err
+	}
 	var jsonData map[string]interface{}
 
 	if err := ctx.BodyParser(&jsonData); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
-	if err := c.validate.Var(jsonData["status"], "required"); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request",
-			"status":  fiber.StatusBadRequest,
-			"error":   err.Error(),
-		})
+	status, _ := jsonData["status"].(string)
+	if err := c.validate.Var(status, "required"); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
 	}
 
-	if err := c.ticketService.UpdateStatusTicket(ticketId, email, jsonData["status"].(string)); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update ticket",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+	version, err := c.ticketService.UpdateStatusTicket(ctx.UserContext(), ticketId, match, principal, status)
+	if err != nil {
+		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, versionETag(version))
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Ticket updated",
 		"status":  fiber.StatusOK,
@@ -201,14 +194,10 @@ func (c *ticketController) UpdateStatusTicket(ctx *fiber.Ctx) error {
 }
 
 func (c *ticketController) Summary(ctx *fiber.Ctx) error {
-	email := ctx.Locals("email").(string)
-	summary, err := c.ticketService.Summary(email)
+	principal := ctx.Locals("principal").(model.Principal)
+	summary, err := c.ticketService.Summary(ctx.UserContext(), principal.Email)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to get summary",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+		return err
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -219,14 +208,10 @@ func (c *ticketController) Summary(ctx *fiber.Ctx) error {
 }
 
 func (c *ticketController) Performance(ctx *fiber.Ctx) error {
-	email := ctx.Locals("email").(string)
-	performance, err := c.ticketService.Performance(email)
+	principal := ctx.Locals("principal").(model.Principal)
+	performance, err := c.ticketService.Performance(ctx.UserContext(), principal.Email)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to get performance",
-			"status":  fiber.StatusInternalServerError,
-			"error":   err.Error(),
-		})
+		return err
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -235,3 +220,158 @@ func (c *ticketController) Performance(ctx *fiber.Ctx) error {
 		"data":    performance,
 	})
 }
+
+func (c *ticketController) DeleteTicket(ctx *fiber.Ctx) error {
+	ticketId := ctx.Params("ticketId")
+	principal := ctx.Locals("principal").(model.Principal)
+	match, err := ifMatchVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.ticketService.DeleteTicket(ctx.UserContext(), ticketId, match, principal); err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Ticket deleted",
+		"status":  fiber.StatusOK,
+	})
+}
+
+func (c *ticketController) RestoreTicket(ctx *fiber.Ctx) error {
+	ticketId := ctx.Params("ticketId")
+	principal := ctx.Locals("principal").(model.Principal)
+
+	if err := c.ticketService.RestoreTicket(ctx.UserContext(), ticketId, principal); err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Ticket restored",
+		"status":  fiber.StatusOK,
+	})
+}
+
+func (c *ticketController) PurgeDeletedTickets(ctx *fiber.Ctx) error {
+	principal := ctx.Locals("principal").(model.Principal)
+
+	purged, err := c.ticketService.PurgeDeletedTickets(ctx.UserContext(), principal)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Deleted tickets purged",
+		"status":  fiber.StatusOK,
+		"data": fiber.Map{
+			"purged": purged,
+		},
+	})
+}
+
+func ticketFilterFromQuery(query model.TicketQuery) (model.TicketFilter, error) {
+	filter := model.TicketFilter{
+		MinPoint: query.MinPoint,
+		MaxPoint: query.MaxPoint,
+		Title:    strings.TrimSpace(query.Title),
+		Sort:     query.Sort,
+		Order:    query.Order,
+		Limit:    query.Limit,
+	}
+	if filter.Sort == "" {
+		filter.Sort = "created_at"
+	}
+	if filter.Order == "" {
+		filter.Order = "desc"
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	for _, status := range strings.Split(query.Status, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if query.Assignee != "" {
+		assigneeId, err := uuid.Parse(query.Assignee)
+		if err != nil {
+			return model.TicketFilter{}, err
+		}
+		filter.AssigneeId = &assigneeId
+	}
+
+	dates := []struct {
+		value  string
+		target **time.Time
+	}{
+		{query.CreatedFrom, &filter.CreatedFrom},
+		{query.CreatedTo, &filter.CreatedTo},
+		{query.UpdatedFrom, &filter.UpdatedFrom},
+		{query.UpdatedTo, &filter.UpdatedTo},
+	}
+	for _, d := range dates {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, d.value)
+		if err != nil {
+			return model.TicketFilter{}, err
+		}
+		*d.target = &t
+	}
+
+	if query.Cursor != "" {
+		cursor, err := helper.DecodeTicketCursor(filter, query.Cursor)
+		if err != nil {
+			return model.TicketFilter{}, err
+		}
+		filter.Cursor = cursor
+	}
+
+	return filter, nil
+}
+
+func versionETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatchVersions parses If-Match into the ticket versions a write may
+// apply to. ETags are compared strongly, so weak ones never match and the
+// write fails its precondition.
+func ifMatchVersions(ctx *fiber.Ctx) (model.VersionMatch, error) {
+	ifMatch := strings.TrimSpace(ctx.Get(fiber.HeaderIfMatch))
+	if ifMatch == "" {
+		return model.VersionMatch{}, apperror.PreconditionRequired("If-Match header is required", nil)
+	}
+	if ifMatch == "*" {
+		return model.VersionMatch{Any: true}, nil
+	}
+
+	var match model.VersionMatch
+	for _, etag := range strings.Split(ifMatch, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "" {
+			continue
+		}
+
+		weak := strings.HasPrefix(etag, "W/")
+		quoted := strings.TrimPrefix(etag, "W/")
+		if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+			return model.VersionMatch{}, apperror.InvalidArgument("If-Match must be a list of ticket ETags", nil)
+		}
+		if weak {
+			continue
+		}
+		opaque := quoted[1 : len(quoted)-1]
+
+		// A well-formed ETag that isn't a version can't be current.
+		if version, err := strconv.Atoi(opaque); err == nil {
+			match.Versions = append(match.Versions, version)
+		}
+	}
+
+	return match, nil
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/service"
+	"github.com/gemm123/vkrf-ticket/middleware"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strings"
+	"time"
+)
+
+type ticketGrpcController struct {
+	grpcserver.UnimplementedTicketServiceServer
+	ticketService service.TicketService
+	validate      *validator.Validate
+}
+
+func NewTicketGrpcController(ticketService service.TicketService, validate *validator.Validate) grpcserver.TicketServiceServer {
+	return &ticketGrpcController{ticketService: ticketService, validate: validate}
+}
+
+func (c *ticketGrpcController) Create(ctx context.Context, req *grpcserver.CreateTicketRequest) (*grpcserver.CreateTicketResponse, error) {
+	principal, err := grpcPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := model.TicketRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      req.Status,
+		Point:       int(req.Point),
+	}
+	if err := c.validate.Struct(ticket); err != nil {
+		return nil, apperror.InvalidArgument("Invalid request", err)
+	}
+
+	ticketId, err := c.ticketService.CreateTicket(ctx, ticket, principal.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcserver.CreateTicketResponse{TicketId: ticketId}, nil
+}
+
+func (c *ticketGrpcController) Get(ctx context.Context, req *grpcserver.GetTicketRequest) (*grpcserver.GetTicketResponse, error) {
+	detailTicket, err := c.ticketService.GetDetailTicket(ctx, req.TicketId)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*grpcserver.HistoryEntryProto, 0, len(detailTicket.HistoryTicketResponse))
+	for _, htr := range detailTicket.HistoryTicketResponse {
+		entry := &grpcserver.HistoryEntryProto{
+			EventType:    string(htr.EventType),
+			Title:        htr.Title,
+			User:         htr.User,
+			OldValueJson: string(htr.OldValue),
+			NewValueJson: string(htr.NewValue),
+			CreatedAt:    timestamppb.New(htr.CreatedAt),
+		}
+		if htr.ActorId != nil {
+			entry.ActorId = htr.ActorId.String()
+		}
+		for _, change := range htr.Changes {
+			entry.Changes = append(entry.Changes, &grpcserver.FieldChangeProto{
+				Field:        change.Field,
+				OldValueJson: marshalValue(change.OldValue),
+				NewValueJson: marshalValue(change.NewValue),
+			})
+		}
+		history = append(history, entry)
+	}
+
+	return &grpcserver.GetTicketResponse{
+		Ticket: &grpcserver.TicketDetailProto{
+			Id:          detailTicket.Id,
+			Username:    detailTicket.Username,
+			ProfilePic:  detailTicket.ProfilePic,
+			Title:       detailTicket.Title,
+			Description: detailTicket.Description,
+			Status:      detailTicket.Status,
+			Point:       int32(detailTicket.Point),
+			Version:     int32(detailTicket.Version),
+			History:     history,
+			UserUnknown: detailTicket.UserUnknown,
+		},
+	}, nil
+}
+
+func (c *ticketGrpcController) List(ctx context.Context, req *grpcserver.ListTicketsRequest) (*grpcserver.ListTicketsResponse, error) {
+	query := model.TicketQuery{
+		Status:   strings.Join(req.Statuses, ","),
+		Assignee: req.AssigneeId,
+		Title:    req.Title,
+		Sort:     req.Sort,
+		Order:    req.Order,
+		Limit:    int(req.Limit),
+	}
+	if req.MinPoint != nil {
+		minPoint := int(*req.MinPoint)
+		query.MinPoint = &minPoint
+	}
+	if req.MaxPoint != nil {
+		maxPoint := int(*req.MaxPoint)
+		query.MaxPoint = &maxPoint
+	}
+
+	if err := c.validate.Struct(query); err != nil {
+		return nil, apperror.InvalidArgument("Invalid request", err)
+	}
+
+	filter, err := ticketFilterFromQuery(query)
+	if err != nil {
+		return nil, apperror.InvalidArgument("Invalid request", err)
+	}
+
+	// Timestamps go straight into the filter rather than through the query's
+	// RFC 3339 strings, which would drop sub-second precision. The cursor is
+	// decoded afterwards because it is bound to the complete filter.
+	dates := []struct {
+		value  *timestamppb.Timestamp
+		target **time.Time
+	}{
+		{req.CreatedFrom, &filter.CreatedFrom},
+		{req.CreatedTo, &filter.CreatedTo},
+		{req.UpdatedFrom, &filter.UpdatedFrom},
+		{req.UpdatedTo, &filter.UpdatedTo},
+	}
+	for _, d := range dates {
+		if d.value == nil {
+			continue
+		}
+		if err := d.value.CheckValid(); err != nil {
+			return nil, apperror.InvalidArgument("Invalid request", err)
+		}
+		t := d.value.AsTime()
+		*d.target = &t
+	}
+
+	if req.Cursor != "" {
+		filter.Cursor, err = helper.DecodeTicketCursor(filter, req.Cursor)
+		if err != nil {
+			return nil, apperror.InvalidArgument("Invalid request", err)
+		}
+	}
+
+	tickets, nextCursor, err := c.ticketService.GetAllTicket(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &grpcserver.ListTicketsResponse{NextCursor: nextCursor}
+	for _, ticket := range tickets {
+		resp.Tickets = append(resp.Tickets, &grpcserver.TicketProto{
+			Id:          ticket.Id.String(),
+			Title:       ticket.Title,
+			Description: ticket.Description,
+			Status:      ticket.Status,
+			Point:       int32(ticket.Point),
+			User:        ticket.User,
+			ProfilePic:  ticket.ProfilePic,
+			UserUnknown: ticket.UserUnknown,
+		})
+	}
+
+	return resp, nil
+}
+
+func (c *ticketGrpcController) UpdateStatus(ctx context.Context, req *grpcserver.UpdateStatusRequest) (*grpcserver.UpdateTicketResponse, error) {
+	principal, err := grpcPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireVersion(req.Version); err != nil {
+		return nil, err
+	}
+	if err := c.validate.Var(req.Status, "required"); err != nil {
+		return nil, apperror.InvalidArgument("Invalid request", err)
+	}
+
+	version, err := c.ticketService.UpdateStatusTicket(ctx, req.TicketId, model.VersionMatch{Versions: []int{int(req.Version)}}, principal, req.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcserver.UpdateTicketResponse{Version: int32(version)}, nil
+}
+
+func (c *ticketGrpcController) Assign(ctx context.Context, req *grpcserver.AssignRequest) (*grpcserver.UpdateTicketResponse, error) {
+	principal, err := grpcPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireVersion(req.Version); err != nil {
+		return nil, err
+	}
+	if err := c.validate.Var(req.AssigneeEmail, "required,email"); err != nil {
+		return nil, apperror.InvalidArgument("Invalid request", err)
+	}
+
+	version, err := c.ticketService.UpdateUserTicket(ctx, req.AssigneeEmail, req.TicketId, model.VersionMatch{Versions: []int{int(req.Version)}}, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcserver.UpdateTicketResponse{Version: int32(version)}, nil
+}
+
+func (c *ticketGrpcController) Edit(ctx context.Context, req *grpcserver.EditRequest) (*grpcserver.UpdateTicketResponse, error) {
+	principal, err := grpcPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireVersion(req.Version); err != nil {
+		return nil, err
+	}
+
+	editTicket := model.EditTicketRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		Point:       int(req.Point),
+	}
+	if err := c.validate.Struct(editTicket); err != nil {
+		return nil, apperror.InvalidArgument("Invalid request", err)
+	}
+
+	version, err := c.ticketService.UpdateEditTicket(ctx, req.TicketId, model.VersionMatch{Versions: []int{int(req.Version)}}, principal, editTicket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcserver.UpdateTicketResponse{Version: int32(version)}, nil
+}
+
+func (c *ticketGrpcController) Summary(ctx context.Context, req *grpcserver.SummaryRequest) (*grpcserver.SummaryResponse, error) {
+	principal, err := grpcPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := c.ticketService.Summary(ctx, principal.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &grpcserver.SummaryResponse{}
+	for _, s := range summary {
+		resp.Entries = append(resp.Entries, &grpcserver.SummaryEntryProto{
+			Status:    s.Status,
+			TotalTask: int32(s.TotalTask),
+			Point:     int32(s.Point),
+		})
+	}
+
+	return resp, nil
+}
+
+func (c *ticketGrpcController) Performance(ctx context.Context, req *grpcserver.PerformanceRequest) (*grpcserver.PerformanceResponse, error) {
+	principal, err := grpcPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	performance, err := c.ticketService.Performance(ctx, principal.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcserver.PerformanceResponse{
+		CompletedTask:            int32(performance.CompletedTask),
+		UncompletedTask:          int32(performance.UnCompletedTask),
+		TotalTask:                int32(performance.TotalTask),
+		CompletedTaskPercentage:  performance.CompletedTaskPercentage,
+		CompletedPoint:           int32(performance.CompletedPoint),
+		UncompletedPoint:         int32(performance.UnCompletedPoint),
+		TotalPoint:               int32(performance.TotalPoint),
+		CompletedPointPercentage: performance.CompletedPointPercentage,
+	}, nil
+}
+
+func grpcPrincipal(ctx context.Context) (model.Principal, error) {
+	principal, ok := middleware.PrincipalFromContext(ctx)
+	if !ok {
+		return model.Principal{}, apperror.Unauthenticated("Missing token", nil)
+	}
+	return principal, nil
+}
+
+func requireVersion(version int32) error {
+	if version <= 0 {
+		return apperror.PreconditionRequired("version is required", nil)
+	}
+	return nil
+}
+
+func marshalValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
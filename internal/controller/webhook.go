@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/service"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type webhookController struct {
+	webhookService service.WebhookService
+	validate       *validator.Validate
+}
+
+type WebhookController interface {
+	CreateWebhook(ctx *fiber.Ctx) error
+	GetWebhooks(ctx *fiber.Ctx) error
+	GetWebhook(ctx *fiber.Ctx) error
+	UpdateWebhook(ctx *fiber.Ctx) error
+	DeleteWebhook(ctx *fiber.Ctx) error
+	GetDeliveries(ctx *fiber.Ctx) error
+	Redeliver(ctx *fiber.Ctx) error
+}
+
+func NewWebhookController(webhookService service.WebhookService, validate *validator.Validate) WebhookController {
+	return &webhookController{webhookService: webhookService, validate: validate}
+}
+
+func (c *webhookController) CreateWebhook(ctx *fiber.Ctx) error {
+	principal := ctx.Locals("principal").(model.Principal)
+	webhook := model.WebhookRequest{}
+	if err := ctx.BodyParser(&webhook); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(webhook); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	created, err := c.webhookService.CreateWebhook(ctx.UserContext(), principal, webhook)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Webhook created",
+		"status":  fiber.StatusCreated,
+		"data":    created,
+	})
+}
+
+func (c *webhookController) GetWebhooks(ctx *fiber.Ctx) error {
+	principal := ctx.Locals("principal").(model.Principal)
+
+	webhooks, err := c.webhookService.GetWebhooks(ctx.UserContext(), principal)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Success",
+		"status":  fiber.StatusOK,
+		"data":    webhooks,
+	})
+}
+
+func (c *webhookController) GetWebhook(ctx *fiber.Ctx) error {
+	webhookId := ctx.Params("webhookId")
+	principal := ctx.Locals("principal").(model.Principal)
+
+	webhook, err := c.webhookService.GetWebhook(ctx.UserContext(), webhookId, principal)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Success",
+		"status":  fiber.StatusOK,
+		"data":    webhook,
+	})
+}
+
+func (c *webhookController) UpdateWebhook(ctx *fiber.Ctx) error {
+	webhookId := ctx.Params("webhookId")
+	principal := ctx.Locals("principal").(model.Principal)
+	webhook := model.WebhookRequest{}
+	if err := ctx.BodyParser(&webhook); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(webhook); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	updated, err := c.webhookService.UpdateWebhook(ctx.UserContext(), webhookId, principal, webhook)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Webhook updated",
+		"status":  fiber.StatusOK,
+		"data":    updated,
+	})
+}
+
+func (c *webhookController) DeleteWebhook(ctx *fiber.Ctx) error {
+	webhookId := ctx.Params("webhookId")
+	principal := ctx.Locals("principal").(model.Principal)
+
+	if err := c.webhookService.DeleteWebhook(ctx.UserContext(), webhookId, principal); err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Webhook deleted",
+		"status":  fiber.StatusOK,
+	})
+}
+
+func (c *webhookController) GetDeliveries(ctx *fiber.Ctx) error {
+	webhookId := ctx.Params("webhookId")
+	principal := ctx.Locals("principal").(model.Principal)
+	query := model.WebhookDeliveryQuery{}
+	if err := ctx.QueryParser(&query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+
+	if err := c.validate.Struct(query); err != nil {
+		return apperror.InvalidArgument("Invalid request", err)
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	deliveries, err := c.webhookService.GetDeliveries(ctx.UserContext(), webhookId, query.Limit, principal)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Success",
+		"status":  fiber.StatusOK,
+		"data":    deliveries,
+	})
+}
+
+func (c *webhookController) Redeliver(ctx *fiber.Ctx) error {
+	webhookId := ctx.Params("webhookId")
+	deliveryId := ctx.Params("deliveryId")
+	principal := ctx.Locals("principal").(model.Principal)
+
+	delivery, err := c.webhookService.Redeliver(ctx.UserContext(), webhookId, deliveryId, principal)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Redelivery queued",
+		"status":  fiber.StatusAccepted,
+		"data":    delivery,
+	})
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"github.com/gemm123/vkrf-ticket/internal/workflow"
+	"github.com/gofiber/fiber/v2"
+)
+
+type workflowController struct {
+	workflow workflow.Workflow
+}
+
+type WorkflowController interface {
+	GetWorkflow(ctx *fiber.Ctx) error
+}
+
+func NewWorkflowController(workflow workflow.Workflow) WorkflowController {
+	return &workflowController{workflow: workflow}
+}
+
+func (c *workflowController) GetWorkflow(ctx *fiber.Ctx) error {
+	from := ctx.Query("from")
+	if from != "" {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Success",
+			"status":  fiber.StatusOK,
+			"data": fiber.Map{
+				"from": from,
+				"next": c.workflow.NextStates(from),
+			},
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Success",
+		"status":  fiber.StatusOK,
+		"data":    c.workflow,
+	})
+}
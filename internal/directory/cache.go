@@ -0,0 +1,312 @@
+package directory
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"golang.org/x/sync/singleflight"
+)
+
+type CacheOptions struct {
+	Size        int
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+type CacheStats struct {
+	Hits         uint64 `json:"hits"`
+	NegativeHits uint64 `json:"negative_hits"`
+	Misses       uint64 `json:"misses"`
+	Evictions    uint64 `json:"evictions"`
+	Entries      int    `json:"entries"`
+}
+
+// batchCall is one id being fetched by a GetUsersByIds batch. Concurrent
+// batches that miss the same id wait on it instead of fetching it again.
+type batchCall struct {
+	done chan struct{}
+	user *grpcserver.UserProto
+	err  error
+}
+
+type cacheEntry struct {
+	key       string
+	user      *grpcserver.UserProto
+	expiresAt time.Time
+}
+
+// CachedUserDirectory is a TTL + LRU cache in front of another UserDirectory.
+// Users are stored under both their id and email, and not-found results are
+// remembered for NegativeTTL so unknown ids don't hammer the user service.
+type CachedUserDirectory struct {
+	next  UserDirectory
+	opts  CacheOptions
+	group singleflight.Group
+
+	inflightMu sync.Mutex
+	inflight   map[string]*batchCall
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits         atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+func NewCachedUserDirectory(next UserDirectory, opts CacheOptions) *CachedUserDirectory {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 5 * time.Minute
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = 30 * time.Second
+	}
+
+	return &CachedUserDirectory{
+		next:     next,
+		opts:     opts,
+		inflight: make(map[string]*batchCall),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (d *CachedUserDirectory) GetUserById(ctx context.Context, userId string) (*grpcserver.UserProto, error) {
+	return d.lookup(ctx, idKey(userId), func(ctx context.Context) (*grpcserver.UserProto, error) {
+		return d.next.GetUserById(ctx, userId)
+	})
+}
+
+func (d *CachedUserDirectory) GetUserByEmail(ctx context.Context, email string) (*grpcserver.UserProto, error) {
+	return d.lookup(ctx, emailKey(email), func(ctx context.Context) (*grpcserver.UserProto, error) {
+		return d.next.GetUserByEmail(ctx, email)
+	})
+}
+
+func (d *CachedUserDirectory) GetUsersByIds(ctx context.Context, userIds []string) (map[string]*grpcserver.UserProto, error) {
+	users := make(map[string]*grpcserver.UserProto, len(userIds))
+	var missing []string
+	for _, userId := range userIds {
+		user, found := d.get(idKey(userId))
+		if !found {
+			missing = append(missing, userId)
+			continue
+		}
+		if user != nil {
+			users[userId] = user
+		}
+	}
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	calls := d.fetchBatch(ctx, missing)
+	for _, userId := range missing {
+		call := calls[userId]
+		select {
+		case <-ctx.Done():
+			return nil, apperror.FromContext(ctx.Err())
+		case <-call.done:
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		if call.user != nil {
+			users[userId] = call.user
+		}
+	}
+
+	return users, nil
+}
+
+// fetchBatch returns an in-flight call for every id, joining the ones another
+// batch is already fetching and fetching the rest in a single request. As in
+// lookup, the fetch isn't tied to the caller's cancellation.
+func (d *CachedUserDirectory) fetchBatch(ctx context.Context, userIds []string) map[string]*batchCall {
+	calls := make(map[string]*batchCall, len(userIds))
+	var owned []string
+
+	d.inflightMu.Lock()
+	for _, userId := range userIds {
+		if call, ok := d.inflight[userId]; ok {
+			calls[userId] = call
+			continue
+		}
+		call := &batchCall{done: make(chan struct{})}
+		d.inflight[userId] = call
+		calls[userId] = call
+		owned = append(owned, userId)
+	}
+	d.inflightMu.Unlock()
+
+	if len(owned) == 0 {
+		return calls
+	}
+
+	go func() {
+		fetched, err := d.next.GetUsersByIds(context.WithoutCancel(ctx), owned)
+		for _, userId := range owned {
+			call := calls[userId]
+			switch user, ok := fetched[userId]; {
+			case err != nil:
+				call.err = err
+			case ok:
+				d.setUser(user)
+				call.user = user
+			default:
+				d.setNegative(idKey(userId))
+			}
+		}
+
+		d.inflightMu.Lock()
+		for _, userId := range owned {
+			delete(d.inflight, userId)
+		}
+		d.inflightMu.Unlock()
+
+		for _, userId := range owned {
+			close(calls[userId].done)
+		}
+	}()
+
+	return calls
+}
+
+func (d *CachedUserDirectory) Stats() CacheStats {
+	d.mu.Lock()
+	entries := d.lru.Len()
+	d.mu.Unlock()
+
+	return CacheStats{
+		Hits:         d.hits.Load(),
+		NegativeHits: d.negativeHits.Load(),
+		Misses:       d.misses.Load(),
+		Evictions:    d.evictions.Load(),
+		Entries:      entries,
+	}
+}
+
+// lookup serves key from the cache or fetches it once for all concurrent
+// callers. The shared fetch isn't tied to any one caller's cancellation, so a
+// caller that gives up only stops waiting for the result.
+func (d *CachedUserDirectory) lookup(ctx context.Context, key string, fetch func(ctx context.Context) (*grpcserver.UserProto, error)) (*grpcserver.UserProto, error) {
+	if user, found := d.get(key); found {
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		return user, nil
+	}
+
+	ch := d.group.DoChan(key, func() (interface{}, error) {
+		user, err := fetch(context.WithoutCancel(ctx))
+		if errors.Is(err, ErrUserNotFound) {
+			d.setNegative(key)
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		d.setUser(user)
+		return user, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, apperror.FromContext(ctx.Err())
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*grpcserver.UserProto), nil
+	}
+}
+
+// get reports whether key is cached; a cached nil user is a negative entry.
+func (d *CachedUserDirectory) get(key string) (*grpcserver.UserProto, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[key]
+	if !ok {
+		d.misses.Add(1)
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		d.removeElement(el)
+		d.misses.Add(1)
+		return nil, false
+	}
+
+	d.lru.MoveToFront(el)
+	if entry.user == nil {
+		d.negativeHits.Add(1)
+	} else {
+		d.hits.Add(1)
+	}
+
+	return entry.user, true
+}
+
+func (d *CachedUserDirectory) setUser(user *grpcserver.UserProto) {
+	expiresAt := time.Now().Add(d.opts.TTL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.set(idKey(user.Id), user, expiresAt)
+	if user.Email != "" {
+		d.set(emailKey(user.Email), user, expiresAt)
+	}
+}
+
+func (d *CachedUserDirectory) setNegative(key string) {
+	expiresAt := time.Now().Add(d.opts.NegativeTTL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.set(key, nil, expiresAt)
+}
+
+func (d *CachedUserDirectory) set(key string, user *grpcserver.UserProto, expiresAt time.Time) {
+	if el, ok := d.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.user = user
+		entry.expiresAt = expiresAt
+		d.lru.MoveToFront(el)
+		return
+	}
+
+	d.entries[key] = d.lru.PushFront(&cacheEntry{key: key, user: user, expiresAt: expiresAt})
+	for d.lru.Len() > d.opts.Size {
+		d.removeElement(d.lru.Back())
+		d.evictions.Add(1)
+	}
+}
+
+func (d *CachedUserDirectory) removeElement(el *list.Element) {
+	d.lru.Remove(el)
+	delete(d.entries, el.Value.(*cacheEntry).key)
+}
+
+func idKey(userId string) string {
+	return "id:" + userId
+}
+
+func emailKey(email string) string {
+	return "email:" + email
+}
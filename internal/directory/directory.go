@@ -0,0 +1,72 @@
+package directory
+
+import (
+	"context"
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrUserNotFound = apperror.NotFound("user not found", nil)
+
+type UserDirectory interface {
+	GetUserById(ctx context.Context, userId string) (*grpcserver.UserProto, error)
+	GetUserByEmail(ctx context.Context, email string) (*grpcserver.UserProto, error)
+	GetUsersByIds(ctx context.Context, userIds []string) (map[string]*grpcserver.UserProto, error)
+}
+
+type grpcUserDirectory struct {
+	client grpcserver.UserServiceClient
+}
+
+func NewGrpcUserDirectory(client grpcserver.UserServiceClient) UserDirectory {
+	return &grpcUserDirectory{client: client}
+}
+
+func (d *grpcUserDirectory) GetUserById(ctx context.Context, userId string) (*grpcserver.UserProto, error) {
+	resp, err := helper.GetUserByUserIdGrpc(ctx, d.client, userId)
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	if resp.User == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return resp.User, nil
+}
+
+func (d *grpcUserDirectory) GetUserByEmail(ctx context.Context, email string) (*grpcserver.UserProto, error) {
+	resp, err := helper.GetUserByEmailGrpc(ctx, d.client, email)
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+	if resp.User == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return resp.User, nil
+}
+
+func (d *grpcUserDirectory) GetUsersByIds(ctx context.Context, userIds []string) (map[string]*grpcserver.UserProto, error) {
+	users, err := helper.GetUsersByIdsGrpc(ctx, d.client, userIds)
+	if err != nil {
+		return nil, translateError(ctx, err)
+	}
+
+	return users, nil
+}
+
+func translateError(ctx context.Context, err error) error {
+	// A call cut short by the caller's deadline or cancellation isn't a user
+	// service failure.
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return apperror.FromContext(ctxErr)
+	}
+	if status.Code(err) == codes.NotFound {
+		return ErrUserNotFound
+	}
+
+	return apperror.Upstream("user service request failed", err)
+}
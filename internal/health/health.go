@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+)
+
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Checker runs the readiness checks of the service's dependencies. Each check
+// gets its own timeout and they run concurrently, so one hanging dependency
+// can't hide the state of the others.
+//
+// A failing critical check takes the service out of rotation. A failing
+// non-critical check only marks the report degraded: the service keeps
+// serving, with reduced functionality, while the dependency is down.
+type Checker struct {
+	checks   map[string]registeredCheck
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+type registeredCheck struct {
+	check    Check
+	critical bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{checks: make(map[string]registeredCheck), timeout: timeout}
+}
+
+func (c *Checker) Register(name string, check Check) {
+	c.checks[name] = registeredCheck{check: check, critical: true}
+}
+
+// RegisterOptional adds a check that is reported but doesn't fail readiness.
+func (c *Checker) RegisterOptional(name string, check Check) {
+	c.checks[name] = registeredCheck{check: check, critical: false}
+}
+
+// Drain makes every following readiness check fail so the orchestrator stops
+// routing traffic while the server shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(c.checks))}
+	if c.draining.Load() {
+		report.Status = StatusDown
+		report.Checks["shutdown"] = CheckResult{Status: StatusDown, Critical: true, Error: "server is shutting down"}
+		return report
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func(name string, check registeredCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			result := CheckResult{Status: StatusUp, Critical: check.critical}
+			if err := check.check(checkCtx); err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+			result.DurationMs = time.Since(start).Milliseconds()
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			switch {
+			case result.Status == StatusUp:
+			case check.critical:
+				report.Status = StatusDown
+			case report.Status == StatusUp:
+				report.Status = StatusDegraded
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func PoolCheck(db *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return db.Ping(ctx)
+	}
+}
+
+// ConnCheck reports a gRPC client connection as healthy once it is READY.
+// An idle connection is asked to connect and given until the check's
+// deadline to get there.
+func ConnCheck(conn *grpc.ClientConn) Check {
+	return func(ctx context.Context) error {
+		for {
+			state := conn.GetState()
+			switch state {
+			case connectivity.Ready:
+				return nil
+			case connectivity.Idle:
+				conn.Connect()
+			case connectivity.Shutdown:
+				return fmt.Errorf("connection is %s", state)
+			}
+
+			if !conn.WaitForStateChange(ctx, state) {
+				return fmt.Errorf("connection is %s", state)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"log/slog"
+)
+
+// RequestIdHeader is the HTTP header carrying the request id; RequestIdMetadata
+// is its gRPC metadata counterpart.
+const (
+	RequestIdHeader   = "X-Request-ID"
+	RequestIdMetadata = "x-request-id"
+)
+
+type requestIdKey struct{}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+func RequestIdFromContext(ctx context.Context) (string, bool) {
+	requestId, ok := ctx.Value(requestIdKey{}).(string)
+	return requestId, ok && requestId != ""
+}
+
+// New returns a JSON logger that adds the request id and trace id found in
+// the context to every record, so logging through slog's *Context functions
+// is enough to correlate a line with its request.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId, ok := RequestIdFromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// UnaryClientInterceptor forwards the request id to the called service.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if requestId, ok := RequestIdFromContext(ctx); ok {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIdMetadata, requestId)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+type GrpcClientMetrics struct {
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewGrpcClientMetrics(registry prometheus.Registerer) *GrpcClientMetrics {
+	m := &GrpcClientMetrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "calls_total",
+			Help:      "Outgoing gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "call_duration_seconds",
+			Help:      "Outgoing gRPC call latency by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+	}
+	registry.MustRegister(m.calls, m.duration)
+	return m
+}
+
+func (m *GrpcClientMetrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		labels := prometheus.Labels{"method": method, "code": status.Code(err).String()}
+		m.calls.With(labels).Inc()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
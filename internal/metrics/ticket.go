@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"github.com/gemm123/vkrf-ticket/internal/directory"
+	"github.com/gemm123/vkrf-ticket/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"time"
+)
+
+const ticketScrapeTimeout = 5 * time.Second
+
+// ticketCollector counts live tickets per status on every scrape. Every
+// workflow state is reported, at zero when it has no tickets, so a status
+// emptying out shows up as a drop rather than a missing series.
+type ticketCollector struct {
+	ticketRepository repository.TicketRepository
+	states           []string
+	tickets          *prometheus.Desc
+}
+
+func NewTicketCollector(ticketRepository repository.TicketRepository, states []string) prometheus.Collector {
+	return &ticketCollector{
+		ticketRepository: ticketRepository,
+		states:           states,
+		tickets: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "tickets"),
+			"Tickets that are not deleted, by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *ticketCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tickets
+}
+
+func (c *ticketCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), ticketScrapeTimeout)
+	defer cancel()
+
+	counts, err := c.ticketRepository.CountAllTicketGroupByStatus(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not count tickets for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.tickets, err)
+		return
+	}
+
+	byStatus := make(map[string]int, len(c.states))
+	for _, state := range c.states {
+		byStatus[state] = 0
+	}
+	for _, count := range counts {
+		byStatus[count.Status] = count.Count
+	}
+	for status, count := range byStatus {
+		ch <- prometheus.MustNewConstMetric(c.tickets, prometheus.GaugeValue, float64(count), status)
+	}
+}
+
+func RegisterUserCache(registry prometheus.Registerer, cache *directory.CachedUserDirectory) {
+	counter := func(name, help string, value func(directory.CacheStats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "user_cache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return float64(value(cache.Stats()))
+		})
+	}
+
+	registry.MustRegister(
+		counter("hits_total", "User lookups served from the cache.",
+			func(s directory.CacheStats) uint64 { return s.Hits }),
+		counter("negative_hits_total", "Lookups answered by a cached not-found.",
+			func(s directory.CacheStats) uint64 { return s.NegativeHits }),
+		counter("misses_total", "Lookups that went to the user service.",
+			func(s directory.CacheStats) uint64 { return s.Misses }),
+		counter("evictions_total", "Entries evicted to stay within the cache size.",
+			func(s directory.CacheStats) uint64 { return s.Evictions }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "user_cache",
+			Name:      "entries",
+			Help:      "Entries currently cached.",
+		}, func() float64 {
+			return float64(cache.Stats().Entries)
+		}),
+	)
+}
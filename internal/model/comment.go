@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Comment struct {
+	Id        uuid.UUID `json:"id"`
+	TicketId  uuid.UUID `json:"ticket_id"`
+	AuthorId  uuid.UUID `json:"author_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+type CommentQuery struct {
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+}
+
+type CommentCursor struct {
+	CreatedAt time.Time
+	Id        uuid.UUID
+}
+
+type CommentResponse struct {
+	Id            uuid.UUID `json:"id"`
+	TicketId      uuid.UUID `json:"ticket_id"`
+	AuthorId      uuid.UUID `json:"author_id"`
+	Author        string    `json:"author"`
+	ProfilePic    string    `json:"profile_pic"`
+	AuthorUnknown bool      `json:"author_unknown,omitempty"`
+	Body          string    `json:"body"`
+	Edited        bool      `json:"edited"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	EventTicketCreated         = "ticket.created"
+	EventTicketStatusChanged   = "ticket.status_changed"
+	EventTicketAssigneeChanged = "ticket.assignee_changed"
+	EventTicketEdited          = "ticket.edited"
+	EventTicketDeleted         = "ticket.deleted"
+	EventTicketRestored        = "ticket.restored"
+)
+
+type Event struct {
+	Id         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	TicketId   uuid.UUID       `json:"ticket_id"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+type EventPayload struct {
+	ActorId   *uuid.UUID      `json:"actor_id"`
+	ActorName string          `json:"actor_name"`
+	OldValue  json.RawMessage `json:"old_value,omitempty"`
+	NewValue  json.RawMessage `json:"new_value,omitempty"`
+}
+
+var EventTypes = []string{
+	EventTicketCreated,
+	EventTicketStatusChanged,
+	EventTicketAssigneeChanged,
+	EventTicketEdited,
+	EventTicketDeleted,
+	EventTicketRestored,
+}
+
+// PublishResult is the outcome of handing one claimed event to the
+// publisher. Skipped events were claimed but never attempted, e.g. because
+// the relay was shutting down, and are released without counting an attempt.
+type PublishResult struct {
+	EventId uuid.UUID
+	Err     error
+	Skipped bool
+}
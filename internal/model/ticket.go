@@ -1,19 +1,23 @@
 package model
 
 import (
+	"encoding/json"
 	"github.com/google/uuid"
 	"time"
 )
 
 type Ticket struct {
-	Id          uuid.UUID `json:"id"`
-	UserId      uuid.UUID `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	Point       int       `json:"point"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	Id          uuid.UUID  `json:"id"`
+	UserId      uuid.UUID  `json:"user_id"`
+	ReporterId  uuid.UUID  `json:"reporter_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Point       int        `json:"point"`
+	Version     int        `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
 }
 
 type TicketRequest struct {
@@ -37,6 +41,7 @@ type TicketResponse struct {
 	Point       int       `json:"point"`
 	User        string    `json:"user"`
 	ProfilePic  string    `json:"profile_pic"`
+	UserUnknown bool      `json:"user_unknown,omitempty"`
 }
 
 type DetailTicketResponse struct {
@@ -47,23 +52,71 @@ type DetailTicketResponse struct {
 	Description           string `json:"description"`
 	Status                string `json:"status"`
 	Point                 int    `json:"point"`
+	Version               int    `json:"version"`
+	UserUnknown           bool   `json:"user_unknown,omitempty"`
 	HistoryTicketResponse []HistoryTicketResponse
 }
 
 type HistoryTicketResponse struct {
-	Date  string `json:"date"`
-	Title string `json:"title"`
-	User  string `json:"user"`
+	Date      string           `json:"date"`
+	Title     string           `json:"title"`
+	User      string           `json:"user"`
+	EventType HistoryEventType `json:"event_type"`
+	ActorId   *uuid.UUID       `json:"actor_id"`
+	OldValue  json.RawMessage  `json:"old_value"`
+	NewValue  json.RawMessage  `json:"new_value"`
+	Changes   []FieldChange    `json:"changes,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field    string      `json:"field"`
+	OldValue interface{} `json:"old_value"`
+	NewValue interface{} `json:"new_value"`
 }
 
+type HistoryEventType string
+
+const (
+	HistoryEventCreated         HistoryEventType = "created"
+	HistoryEventStatusChanged   HistoryEventType = "status_changed"
+	HistoryEventAssigneeChanged HistoryEventType = "assignee_changed"
+	HistoryEventEdited          HistoryEventType = "edited"
+	HistoryEventCommented       HistoryEventType = "commented"
+	HistoryEventDeleted         HistoryEventType = "deleted"
+	HistoryEventRestored        HistoryEventType = "restored"
+	HistoryEventLegacy          HistoryEventType = "legacy"
+)
+
 type HistoryTicket struct {
-	Id        uuid.UUID `json:"id"`
-	TicketId  uuid.UUID `json:"ticket_id"`
-	Date      string    `json:"date"`
-	Title     string    `json:"title"`
-	User      string    `json:"user"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id        uuid.UUID        `json:"id"`
+	TicketId  uuid.UUID        `json:"ticket_id"`
+	EventType HistoryEventType `json:"event_type"`
+	ActorId   *uuid.UUID       `json:"actor_id"`
+	ActorName string           `json:"actor_name"`
+	OldValue  json.RawMessage  `json:"old_value"`
+	NewValue  json.RawMessage  `json:"new_value"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// VersionMatch is the set of ticket versions a write is conditioned on, taken
+// from If-Match. Any matches whatever version the ticket currently has.
+type VersionMatch struct {
+	Any      bool
+	Versions []int
+}
+
+func (m VersionMatch) Matches(version int) bool {
+	if m.Any {
+		return true
+	}
+	for _, v := range m.Versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
 }
 
 type CountTicket struct {
@@ -92,3 +145,46 @@ type Performance struct {
 	TotalPoint               int    `json:"totalPoint"`
 	CompletedPointPercentage string `json:"completedPointPercentage"`
 }
+
+type TicketQuery struct {
+	Status      string `query:"status"`
+	Assignee    string `query:"assignee" validate:"omitempty,uuid"`
+	MinPoint    *int   `query:"min_point" validate:"omitempty,min=0"`
+	MaxPoint    *int   `query:"max_point" validate:"omitempty,min=0"`
+	CreatedFrom string `query:"created_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `query:"created_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	UpdatedFrom string `query:"updated_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	UpdatedTo   string `query:"updated_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Title       string `query:"q"`
+	Sort        string `query:"sort" validate:"omitempty,oneof=created_at updated_at point"`
+	Order       string `query:"order" validate:"omitempty,oneof=asc desc"`
+	Limit       int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor      string `query:"cursor"`
+}
+
+type TicketFilter struct {
+	Statuses    []string
+	AssigneeId  *uuid.UUID
+	MinPoint    *int
+	MaxPoint    *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Title       string
+	Sort        string
+	Order       string
+	Limit       int
+	Cursor      *TicketCursor
+}
+
+type TicketCursor struct {
+	Value interface{}
+	Id    uuid.UUID
+}
+
+type TicketStreamQuery struct {
+	Assignee    string `query:"assignee" validate:"omitempty,uuid"`
+	Status      string `query:"status"`
+	LastEventId string `query:"last_event_id"`
+}
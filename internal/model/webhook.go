@@ -0,0 +1,72 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	Id         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedBy  uuid.UUID `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required,url,startswith=http"`
+	EventTypes []string `json:"event_types" validate:"dive,required"`
+	Active     *bool    `json:"active"`
+}
+
+// CreatedWebhookResponse is only returned when a webhook is created; it is the
+// one time the signing secret is shown.
+type CreatedWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type WebhookDelivery struct {
+	Id             uuid.UUID       `json:"id"`
+	WebhookId      uuid.UUID       `json:"webhook_id"`
+	EventId        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	RedeliveryOf   *uuid.UUID      `json:"redelivery_of"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+type WebhookDeliveryQuery struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// PendingDelivery is a claimed delivery together with the webhook it goes to.
+type PendingDelivery struct {
+	Webhook  Webhook
+	Delivery WebhookDelivery
+}
+
+// DeliveryResult is the outcome of one delivery attempt. Skipped deliveries
+// were claimed but never attempted and are released without counting one.
+type DeliveryResult struct {
+	DeliveryId     uuid.UUID
+	ResponseStatus int
+	Err            error
+	Skipped        bool
+}
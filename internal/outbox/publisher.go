@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"net/http"
+	"time"
+)
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// ErrMemoryPublisherFull means nothing drains the memory publisher fast
+// enough. The event is retried by the relay rather than dropped.
+var ErrMemoryPublisherFull = errors.New("memory publisher buffer is full")
+
+// MemoryPublisher queues events on a bounded channel for in-process consumers
+// and tests, which read them from Events or Drain.
+type MemoryPublisher struct {
+	events chan model.Event
+}
+
+func NewMemoryPublisher(size int) *MemoryPublisher {
+	return &MemoryPublisher{events: make(chan model.Event, size)}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event model.Event) error {
+	select {
+	case p.events <- event:
+		return nil
+	default:
+		return ErrMemoryPublisherFull
+	}
+}
+
+func (p *MemoryPublisher) Events() <-chan model.Event {
+	return p.events
+}
+
+// Drain passes queued events to handle until ctx is cancelled.
+func (p *MemoryPublisher) Drain(ctx context.Context, handle func(ctx context.Context, event model.Event)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-p.events:
+			handle(ctx, event)
+		}
+	}
+}
+
+// WebhookPublisher POSTs each event as JSON to a fixed URL. Any non-2xx
+// response is treated as a failure so the relay retries the event.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event model.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", event.Id.String())
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// MultiPublisher publishes every event to each publisher, even after one of
+// them fails, so a sink that is down doesn't hold back the others. Any
+// failure makes the relay retry the event for all of them, which
+// at-least-once consumers already tolerate.
+type MultiPublisher []EventPublisher
+
+func (p MultiPublisher) Publish(ctx context.Context, event model.Event) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,40 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMultiPublisherPublishesPastFailures(t *testing.T) {
+	down := &fakePublisher{err: errors.New("sink is down")}
+	up := &fakePublisher{}
+	event := newEvent()
+
+	err := MultiPublisher{down, up}.Publish(context.Background(), event)
+	if !errors.Is(err, down.err) {
+		t.Fatalf("err = %v, want the failing sink's error", err)
+	}
+	if len(up.published) != 1 || up.published[0] != event.Id {
+		t.Errorf("published = %v, want the event on the healthy sink", up.published)
+	}
+}
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := NewMemoryPublisher(1)
+	first, second := newEvent(), newEvent()
+
+	if err := publisher.Publish(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Publish(context.Background(), second); !errors.Is(err, ErrMemoryPublisherFull) {
+		t.Fatalf("err = %v, want ErrMemoryPublisherFull", err)
+	}
+
+	if got := <-publisher.Events(); got.Id != first.Id {
+		t.Fatalf("drained %s, want %s", got.Id, first.Id)
+	}
+	if err := publisher.Publish(context.Background(), second); err != nil {
+		t.Errorf("err = %v after draining, want nil", err)
+	}
+}
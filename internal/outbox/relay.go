@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/repository"
+	"log/slog"
+	"time"
+)
+
+const recordTimeout = 10 * time.Second
+
+type Relay struct {
+	outboxRepository repository.OutboxRepository
+	publisher        EventPublisher
+	interval         time.Duration
+	batchSize        int
+	maxAttempts      int
+	lease            time.Duration
+}
+
+func NewRelay(outboxRepository repository.OutboxRepository, publisher EventPublisher, interval time.Duration, batchSize, maxAttempts int, lease time.Duration) *Relay {
+	return &Relay{
+		outboxRepository: outboxRepository,
+		publisher:        publisher,
+		interval:         interval,
+		batchSize:        batchSize,
+		maxAttempts:      maxAttempts,
+		lease:            lease,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled. A full batch is followed
+// immediately by another poll so a backlog drains without waiting.
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		claimed, err := r.relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Outbox relay failed", "error", err)
+		}
+
+		if claimed == r.batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.interval)
+		}
+	}
+}
+
+// relayBatch claims a batch, publishes it without holding any transaction and
+// records the results. Once ctx is cancelled the rest of the batch is
+// released rather than left leased until the lease expires.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	events, err := r.outboxRepository.ClaimPending(ctx, r.batchSize, r.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]model.PublishResult, 0, len(events))
+	for _, event := range events {
+		if ctx.Err() != nil {
+			results = append(results, model.PublishResult{EventId: event.Id, Skipped: true})
+			continue
+		}
+		err := r.publisher.Publish(ctx, event)
+		results = append(results, model.PublishResult{EventId: event.Id, Err: err, Skipped: err != nil && ctx.Err() != nil})
+	}
+
+	// The outcome is recorded even during shutdown, otherwise events already
+	// published would be published again.
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	return len(events), r.outboxRepository.CompletePublished(recordCtx, r.maxAttempts, results)
+}
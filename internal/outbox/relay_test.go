@@ -0,0 +1,240 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRow struct {
+	event         model.Event
+	attempts      int
+	lastError     string
+	lockedUntil   time.Time
+	nextAttemptAt time.Time
+	published     bool
+	failed        bool
+}
+
+// fakeOutboxRepository keeps the outbox in memory with the claim and
+// completion rules of the SQL implementation, on a clock the test controls.
+type fakeOutboxRepository struct {
+	mu   sync.Mutex
+	now  time.Time
+	rows []*fakeRow
+}
+
+func newFakeOutboxRepository(events ...model.Event) *fakeOutboxRepository {
+	repo := &fakeOutboxRepository{now: time.Now()}
+	for _, event := range events {
+		repo.rows = append(repo.rows, &fakeRow{event: event, nextAttemptAt: repo.now})
+	}
+	return repo
+}
+
+func (r *fakeOutboxRepository) advance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = r.now.Add(d)
+}
+
+func (r *fakeOutboxRepository) row(id uuid.UUID) fakeRow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, row := range r.rows {
+		if row.event.Id == id {
+			return *row
+		}
+	}
+	return fakeRow{}
+}
+
+func (r *fakeOutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []model.Event
+	for _, row := range r.rows {
+		if len(events) == limit {
+			break
+		}
+		if row.published || row.failed || row.nextAttemptAt.After(r.now) || row.lockedUntil.After(r.now) {
+			continue
+		}
+		row.lockedUntil = r.now.Add(lease)
+		events = append(events, row.event)
+	}
+	return events, nil
+}
+
+func (r *fakeOutboxRepository) CompletePublished(ctx context.Context, maxAttempts int, results []model.PublishResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, result := range results {
+		for _, row := range r.rows {
+			if row.event.Id != result.EventId || row.published {
+				continue
+			}
+			row.lockedUntil = time.Time{}
+			switch {
+			case result.Skipped:
+			case result.Err != nil:
+				row.attempts++
+				row.lastError = result.Err.Error()
+				row.failed = row.attempts >= maxAttempts
+				row.nextAttemptAt = r.now.Add(time.Duration(1<<(row.attempts-1)) * time.Second)
+			default:
+				row.attempts++
+				row.lastError = ""
+				row.published = true
+			}
+		}
+	}
+	return nil
+}
+
+type fakePublisher struct {
+	mu        sync.Mutex
+	err       error
+	published []uuid.UUID
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, event model.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event.Id)
+	return nil
+}
+
+func newEvent() model.Event {
+	return model.Event{Id: uuid.New(), Type: model.EventTicketCreated, TicketId: uuid.New()}
+}
+
+func TestRelayFailureLeavesEventPending(t *testing.T) {
+	event := newEvent()
+	repo := newFakeOutboxRepository(event)
+	publisher := &fakePublisher{err: errors.New("sink is down")}
+	relay := NewRelay(repo, publisher, time.Second, 10, 5, time.Minute)
+
+	if _, err := relay.relayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	row := repo.row(event.Id)
+	if row.published || row.failed {
+		t.Fatalf("published = %v, failed = %v, want the event still pending", row.published, row.failed)
+	}
+	if row.attempts != 1 || row.lastError != "sink is down" {
+		t.Errorf("attempts = %d, last error = %q, want 1 and the publish error", row.attempts, row.lastError)
+	}
+	if !row.lockedUntil.IsZero() {
+		t.Error("lease was not released")
+	}
+
+	// The event is retried once its backoff has passed.
+	publisher.err = nil
+	if claimed, _ := relay.relayBatch(context.Background()); claimed != 0 {
+		t.Fatalf("claimed %d events during the backoff, want 0", claimed)
+	}
+	repo.advance(time.Second)
+	if _, err := relay.relayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if row := repo.row(event.Id); !row.published || row.attempts != 2 {
+		t.Errorf("published = %v, attempts = %d, want published on the second attempt", row.published, row.attempts)
+	}
+}
+
+func TestRelayMarksEventFailedAfterMaxAttempts(t *testing.T) {
+	event := newEvent()
+	repo := newFakeOutboxRepository(event)
+	relay := NewRelay(repo, &fakePublisher{err: errors.New("sink is down")}, time.Second, 10, 3, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		if _, err := relay.relayBatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		repo.advance(time.Hour)
+	}
+
+	if row := repo.row(event.Id); !row.failed || row.attempts != 3 {
+		t.Errorf("failed = %v, attempts = %d, want failed after 3 attempts", row.failed, row.attempts)
+	}
+}
+
+func TestRelayCompletesSuccessOnce(t *testing.T) {
+	events := []model.Event{newEvent(), newEvent()}
+	repo := newFakeOutboxRepository(events...)
+	publisher := &fakePublisher{}
+	relay := NewRelay(repo, publisher, time.Second, 10, 5, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := relay.relayBatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		repo.advance(time.Hour)
+	}
+
+	if len(publisher.published) != len(events) {
+		t.Fatalf("published %d times, want each of the %d events once", len(publisher.published), len(events))
+	}
+	for _, event := range events {
+		if row := repo.row(event.Id); !row.published || row.attempts != 1 {
+			t.Errorf("published = %v, attempts = %d, want published after one attempt", row.published, row.attempts)
+		}
+	}
+}
+
+func TestRelayReclaimsExpiredLease(t *testing.T) {
+	event := newEvent()
+	repo := newFakeOutboxRepository(event)
+	publisher := &fakePublisher{}
+	relay := NewRelay(repo, publisher, time.Second, 10, 5, time.Minute)
+
+	// Another relay claimed the event and died before recording anything.
+	if _, err := repo.ClaimPending(context.Background(), 10, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, _ := relay.relayBatch(context.Background()); claimed != 0 {
+		t.Fatalf("claimed %d events under a live lease, want 0", claimed)
+	}
+
+	repo.advance(time.Minute)
+	if claimed, _ := relay.relayBatch(context.Background()); claimed != 1 {
+		t.Fatalf("claimed %d events after the lease expired, want 1", claimed)
+	}
+	if row := repo.row(event.Id); !row.published {
+		t.Error("reclaimed event was not published")
+	}
+}
+
+func TestRelayReleasesBatchWhenCancelled(t *testing.T) {
+	event := newEvent()
+	repo := newFakeOutboxRepository(event)
+	publisher := &fakePublisher{}
+	relay := NewRelay(repo, publisher, time.Second, 10, 5, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := relay.relayBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	row := repo.row(event.Id)
+	if row.published || row.attempts != 0 || !row.lockedUntil.IsZero() {
+		t.Errorf("published = %v, attempts = %d, locked = %v, want released without an attempt",
+			row.published, row.attempts, !row.lockedUntil.IsZero())
+	}
+	if len(publisher.published) != 0 {
+		t.Errorf("published %d events after cancellation, want 0", len(publisher.published))
+	}
+}
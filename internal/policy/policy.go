@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gemm123/vkrf-ticket/internal/model"
+)
+
+type Role string
+
+const (
+	RoleReporter Role = "reporter"
+	RoleAssignee Role = "assignee"
+	RoleAdmin    Role = "admin"
+	RoleViewer   Role = "viewer"
+)
+
+type Action string
+
+const (
+	ActionUpdateStatus Action = "ticket:update_status"
+	ActionEdit         Action = "ticket:edit"
+	ActionAssign       Action = "ticket:assign"
+	ActionDelete       Action = "ticket:delete"
+	ActionRestore      Action = "ticket:restore"
+)
+
+type Rules map[Action][]Role
+
+type DeniedError struct {
+	Action Action
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%s denied: %s", e.Action, e.Reason)
+}
+
+type Policy struct {
+	rules Rules
+}
+
+func DefaultRules() Rules {
+	return Rules{
+		ActionUpdateStatus: {RoleReporter, RoleAssignee, RoleAdmin},
+		ActionEdit:         {RoleReporter, RoleAdmin},
+		ActionAssign:       {RoleReporter, RoleAssignee, RoleAdmin},
+		ActionDelete:       {RoleReporter, RoleAdmin},
+		ActionRestore:      {RoleReporter, RoleAdmin},
+	}
+}
+
+func NewPolicy(rules Rules) *Policy {
+	return &Policy{rules: rules}
+}
+
+// ParseRules reads overrides in the form
+// "ticket:edit=reporter,admin;ticket:assign=admin" on top of DefaultRules.
+func ParseRules(s string) (Rules, error) {
+	rules := DefaultRules()
+	if strings.TrimSpace(s) == "" {
+		return rules, nil
+	}
+
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		action, roleList, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid policy entry %q", entry)
+		}
+		a := Action(strings.TrimSpace(action))
+		if _, ok := rules[a]; !ok {
+			return nil, fmt.Errorf("unknown policy action %q", a)
+		}
+
+		var roles []Role
+		for _, r := range strings.Split(roleList, ",") {
+			role := Role(strings.TrimSpace(r))
+			switch role {
+			case RoleReporter, RoleAssignee, RoleAdmin, RoleViewer:
+				roles = append(roles, role)
+			case "":
+			default:
+				return nil, fmt.Errorf("unknown policy role %q", role)
+			}
+		}
+		rules[a] = roles
+	}
+
+	return rules, nil
+}
+
+// RolesFor returns the roles the actor holds on the ticket. Admin and viewer
+// come from the token, reporter and assignee from the ticket itself.
+func RolesFor(actorId string, principal model.Principal, ticket model.Ticket) []Role {
+	roles := []Role{RoleViewer}
+	if principal.HasRole(string(RoleAdmin)) {
+		roles = append(roles, RoleAdmin)
+	}
+	if ticket.ReporterId.String() == actorId {
+		roles = append(roles, RoleReporter)
+	}
+	if ticket.UserId.String() == actorId {
+		roles = append(roles, RoleAssignee)
+	}
+
+	return roles
+}
+
+func (p *Policy) Authorize(action Action, actorId string, principal model.Principal, ticket model.Ticket) error {
+	allowed := p.rules[action]
+	held := RolesFor(actorId, principal, ticket)
+
+	for _, a := range allowed {
+		for _, h := range held {
+			if a == h {
+				return nil
+			}
+		}
+	}
+
+	return &DeniedError{
+		Action: action,
+		Reason: fmt.Sprintf("requires one of [%s], caller has [%s]", joinRoles(allowed), joinRoles(held)),
+	}
+}
+
+func joinRoles(roles []Role) string {
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, string(r))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,134 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	reporterId = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	assigneeId = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	adminId    = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	otherId    = uuid.MustParse("00000000-0000-0000-0000-000000000004")
+)
+
+func TestAuthorizeDefaultRules(t *testing.T) {
+	ticket := model.Ticket{ReporterId: reporterId, UserId: assigneeId}
+	// The owner both reported the ticket and is working on it, the usual
+	// case for a ticket someone creates for themselves.
+	ownTicket := model.Ticket{ReporterId: reporterId, UserId: reporterId}
+
+	actors := []struct {
+		name      string
+		actorId   uuid.UUID
+		principal model.Principal
+		ticket    model.Ticket
+	}{
+		{"owner", reporterId, model.Principal{}, ownTicket},
+		{"reporter", reporterId, model.Principal{}, ticket},
+		{"assignee", assigneeId, model.Principal{}, ticket},
+		{"admin", adminId, model.Principal{Roles: []string{"admin"}}, ticket},
+		{"other", otherId, model.Principal{}, ticket},
+	}
+
+	tests := []struct {
+		action  Action
+		allowed map[string]bool
+	}{
+		{ActionUpdateStatus, map[string]bool{"owner": true, "reporter": true, "assignee": true, "admin": true}},
+		{ActionEdit, map[string]bool{"owner": true, "reporter": true, "admin": true}},
+		{ActionAssign, map[string]bool{"owner": true, "reporter": true, "assignee": true, "admin": true}},
+		{ActionDelete, map[string]bool{"owner": true, "reporter": true, "admin": true}},
+		{ActionRestore, map[string]bool{"owner": true, "reporter": true, "admin": true}},
+	}
+
+	p := NewPolicy(DefaultRules())
+	for _, tt := range tests {
+		for _, actor := range actors {
+			t.Run(string(tt.action)+"/"+actor.name, func(t *testing.T) {
+				err := p.Authorize(tt.action, actor.actorId.String(), actor.principal, actor.ticket)
+
+				if tt.allowed[actor.name] {
+					if err != nil {
+						t.Fatalf("expected allowed, got %v", err)
+					}
+					return
+				}
+				var denied *DeniedError
+				if !errors.As(err, &denied) {
+					t.Fatalf("expected *DeniedError, got %v", err)
+				}
+				if denied.Action != tt.action {
+					t.Errorf("denied action = %q, want %q", denied.Action, tt.action)
+				}
+			})
+		}
+	}
+}
+
+func TestAuthorizeOverriddenRules(t *testing.T) {
+	rules, err := ParseRules("ticket:assign=admin; ticket:update_status=assignee")
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	p := NewPolicy(rules)
+	ticket := model.Ticket{ReporterId: reporterId, UserId: assigneeId}
+	admin := model.Principal{Roles: []string{"admin"}}
+
+	tests := []struct {
+		name      string
+		action    Action
+		actorId   uuid.UUID
+		principal model.Principal
+		allowed   bool
+	}{
+		{"reporter can no longer assign", ActionAssign, reporterId, model.Principal{}, false},
+		{"admin can still assign", ActionAssign, adminId, admin, true},
+		{"assignee can change status", ActionUpdateStatus, assigneeId, model.Principal{}, true},
+		{"admin can no longer change status", ActionUpdateStatus, adminId, admin, false},
+		{"untouched rule keeps its default", ActionEdit, reporterId, model.Principal{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(tt.action, tt.actorId.String(), tt.principal, ticket)
+			if allowed := err == nil; allowed != tt.allowed {
+				t.Fatalf("allowed = %v, want %v (err: %v)", allowed, tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestRolesFor(t *testing.T) {
+	ticket := model.Ticket{ReporterId: reporterId, UserId: reporterId}
+	roles := RolesFor(reporterId.String(), model.Principal{Roles: []string{"admin"}}, ticket)
+
+	want := []Role{RoleViewer, RoleAdmin, RoleReporter, RoleAssignee}
+	if len(roles) != len(want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Fatalf("roles = %v, want %v", roles, want)
+		}
+	}
+}
+
+func TestParseRulesRejectsUnknownNames(t *testing.T) {
+	tests := []string{
+		"ticket:fly=admin",
+		"ticket:edit=owner",
+		"ticket:edit",
+	}
+
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			if _, err := ParseRules(s); err == nil {
+				t.Fatalf("ParseRules(%q) returned no error", s)
+			}
+		})
+	}
+}
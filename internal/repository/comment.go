@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrCommentNotFound = apperror.NotFound("comment not found", nil)
+
+type commentRepository struct {
+	db *pgxpool.Pool
+}
+
+type CommentRepository interface {
+	CreateComment(ctx context.Context, comment model.Comment, historyTicket model.HistoryTicket) error
+	GetCommentsByTicketId(ctx context.Context, ticketId string, limit int, cursor *model.CommentCursor) ([]model.Comment, error)
+	GetCommentById(ctx context.Context, ticketId, commentId string) (model.Comment, error)
+	UpdateComment(ctx context.Context, comment model.Comment) error
+	DeleteComment(ctx context.Context, ticketId, commentId string) error
+}
+
+func NewCommentRepository(db *pgxpool.Pool) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+func (r *commentRepository) CreateComment(ctx context.Context, comment model.Comment, historyTicket model.HistoryTicket) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `INSERT INTO comments (id, ticket_id, author_id, body, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(ctx, query, comment.Id, comment.TicketId, comment.AuthorId, comment.Body,
+		comment.CreatedAt, comment.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = insertHistoryTicket(ctx, tx, historyTicket)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *commentRepository) GetCommentsByTicketId(ctx context.Context, ticketId string, limit int, cursor *model.CommentCursor) ([]model.Comment, error) {
+	query := `SELECT id, ticket_id, author_id, body, created_at, updated_at FROM comments
+		WHERE ticket_id = $1 ORDER BY created_at, id LIMIT $2`
+	args := []interface{}{ticketId, limit}
+	if cursor != nil {
+		query = `SELECT id, ticket_id, author_id, body, created_at, updated_at FROM comments
+			WHERE ticket_id = $1 AND (created_at, id) > ($3, $4) ORDER BY created_at, id LIMIT $2`
+		args = append(args, cursor.CreatedAt, cursor.Id)
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []model.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (r *commentRepository) GetCommentById(ctx context.Context, ticketId, commentId string) (model.Comment, error) {
+	query := `SELECT id, ticket_id, author_id, body, created_at, updated_at FROM comments WHERE ticket_id = $1 AND id = $2`
+	row := r.db.QueryRow(ctx, query, ticketId, commentId)
+
+	comment, err := scanComment(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Comment{}, ErrCommentNotFound
+	}
+	if err != nil {
+		return model.Comment{}, err
+	}
+
+	return comment, nil
+}
+
+func (r *commentRepository) UpdateComment(ctx context.Context, comment model.Comment) error {
+	query := `UPDATE comments SET body = $1, updated_at = $2 WHERE ticket_id = $3 AND id = $4`
+	tag, err := r.db.Exec(ctx, query, comment.Body, comment.UpdatedAt, comment.TicketId, comment.Id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
+
+func (r *commentRepository) DeleteComment(ctx context.Context, ticketId, commentId string) error {
+	query := `DELETE FROM comments WHERE ticket_id = $1 AND id = $2`
+	tag, err := r.db.Exec(ctx, query, ticketId, commentId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
+
+func scanComment(row pgx.Row) (model.Comment, error) {
+	comment := model.Comment{}
+	err := row.Scan(&comment.Id, &comment.TicketId, &comment.AuthorId, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt)
+
+	return comment, err
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sort"
+	"time"
+)
+
+type outboxRepository struct {
+	db *pgxpool.Pool
+}
+
+type OutboxRepository interface {
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error)
+	CompletePublished(ctx context.Context, maxAttempts int, results []model.PublishResult) error
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// ClaimPending leases up to limit due, unpublished events for lease and
+// returns them oldest first. The claim is committed straight away so no
+// transaction or row lock is held while the events are published; another
+// relay only picks an event up again once its lease has run out, which keeps
+// delivery at least once if this relay dies mid-batch.
+func (r *outboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error) {
+	query := `UPDATE outbox SET locked_until = now() + $2::interval WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= now() AND (locked_until IS NULL OR locked_until <= now())
+			ORDER BY occurred_at, id LIMIT $1 FOR UPDATE SKIP LOCKED)
+		RETURNING id, event_type, ticket_id, payload, occurred_at`
+	rows, err := r.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Event, error) {
+		event := model.Event{}
+		err := row.Scan(&event.Id, &event.Type, &event.TicketId, &event.Payload, &event.OccurredAt)
+		return event, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].OccurredAt.Equal(events[j].OccurredAt) {
+			return events[i].Id.String() < events[j].Id.String()
+		}
+		return events[i].OccurredAt.Before(events[j].OccurredAt)
+	})
+
+	return events, nil
+}
+
+// CompletePublished records the outcome of a claimed batch in one short
+// transaction and releases the leases. Failed events are retried with an
+// exponential backoff capped at one hour until maxAttempts is reached, after
+// which they are marked failed and no longer claimed.
+func (r *outboxRepository) CompletePublished(ctx context.Context, maxAttempts int, results []model.PublishResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			_, err = tx.Exec(ctx, `UPDATE outbox SET locked_until = NULL WHERE id = $1 AND published_at IS NULL`,
+				result.EventId)
+		case result.Err != nil:
+			_, err = tx.Exec(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $1, locked_until = NULL,
+				failed_at = CASE WHEN attempts + 1 >= $2 THEN now() END,
+				next_attempt_at = now() + make_interval(secs => LEAST(power(2, attempts), 3600))
+				WHERE id = $3 AND published_at IS NULL`, result.Err.Error(), maxAttempts, result.EventId)
+		default:
+			_, err = tx.Exec(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = NULL, locked_until = NULL,
+				published_at = now() WHERE id = $1 AND published_at IS NULL`, result.EventId)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, event model.Event) error {
+	query := `INSERT INTO outbox (id, event_type, ticket_id, payload, occurred_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`
+	_, err := tx.Exec(ctx, query, event.Id, event.Type, event.TicketId, event.Payload, event.OccurredAt)
+
+	return err
+}
@@ -2,8 +2,21 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
 	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"time"
+)
+
+const ticketColumns = `id, user_id, reporter_id, title, description, status, point, version, created_at, updated_at, deleted_at`
+
+var (
+	ErrTicketNotFound  = apperror.NotFound("ticket not found", nil)
+	ErrVersionConflict = apperror.PreconditionFailed("ticket was modified by another request", nil)
 )
 
 type ticketRepository struct {
@@ -11,49 +24,57 @@ type ticketRepository struct {
 }
 
 type TicketRepository interface {
-	CreateTicket(ticket model.Ticket, historyTicket model.HistoryTicket) error
-	GetAllTicket() ([]model.Ticket, error)
-	GetHistoryTicketByTicketId(ticketId string) ([]model.HistoryTicket, error)
-	GetTicketById(ticketId string) (model.Ticket, error)
-	UpdateUserTicket(userId, ticketId string, historyTicket model.HistoryTicket) error
-	UpdateEditTicket(editTicket model.EditTicketRequest, ticketId string, historyTicket model.HistoryTicket) error
-	UpdateStatusTicket(status, ticketId string, historyTicket model.HistoryTicket) error
-	CountTicketGroupByStatus(userId string) ([]model.CountTicket, error)
-	SumTicketGroupByStatus(userId string) ([]model.SumPoint, error)
-	CountTicketDone(userId string) (int, error)
-	CountTicket(userId string) (int, error)
-	SumPointTicketDone(userId string) (int, error)
-	SumPointTicket(userId string) (int, error)
+	CreateTicket(ctx context.Context, ticket model.Ticket, historyTicket model.HistoryTicket, event model.Event) error
+	GetAllTicket(ctx context.Context, filter model.TicketFilter) ([]model.Ticket, error)
+	GetHistoryTicketByTicketId(ctx context.Context, ticketId string) ([]model.HistoryTicket, error)
+	GetTicketById(ctx context.Context, ticketId string) (model.Ticket, error)
+	UpdateUserTicket(ctx context.Context, userId, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) (int, error)
+	UpdateEditTicket(ctx context.Context, editTicket model.EditTicketRequest, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) (int, error)
+	UpdateStatusTicket(ctx context.Context, status, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) (int, error)
+	CountTicketGroupByStatus(ctx context.Context, userId string) ([]model.CountTicket, error)
+	SumTicketGroupByStatus(ctx context.Context, userId string) ([]model.SumPoint, error)
+	CountTicketCompleted(ctx context.Context, userId string, completedStatuses []string) (int, error)
+	CountTicket(ctx context.Context, userId string) (int, error)
+	SumPointTicketCompleted(ctx context.Context, userId string, completedStatuses []string) (int, error)
+	SumPointTicket(ctx context.Context, userId string) (int, error)
+	GetDeletedTicketById(ctx context.Context, ticketId string) (model.Ticket, error)
+	DeleteTicket(ctx context.Context, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) error
+	RestoreTicket(ctx context.Context, ticketId string, historyTicket model.HistoryTicket, event model.Event) error
+	PurgeDeletedTickets(ctx context.Context, deletedBefore time.Time) (int64, error)
+	CountAllTicketGroupByStatus(ctx context.Context) ([]model.CountTicket, error)
 }
 
 func NewTicketRepository(db *pgxpool.Pool) TicketRepository {
 	return &ticketRepository{db: db}
 }
 
-func (r *ticketRepository) CreateTicket(ticket model.Ticket, historyTicket model.HistoryTicket) error {
-	tx, err := r.db.Begin(context.Background())
+func (r *ticketRepository) CreateTicket(ctx context.Context, ticket model.Ticket, historyTicket model.HistoryTicket, event model.Event) error {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(context.Background())
 
-	query := `INSERT INTO tickets (id, user_id, title, description, status, point, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err = tx.Exec(context.Background(), query,
-		ticket.Id, ticket.UserId, ticket.Title, ticket.Description, ticket.Status, ticket.Point,
-		ticket.CreatedAt, ticket.UpdatedAt)
+	query := `INSERT INTO tickets (id, user_id, reporter_id, title, description, status, point, version, created_at, updated_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = tx.Exec(ctx, query,
+		ticket.Id, ticket.UserId, ticket.ReporterId, ticket.Title, ticket.Description, ticket.Status, ticket.Point,
+		ticket.Version, ticket.CreatedAt, ticket.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = insertHistoryTicket(ctx, tx, historyTicket)
 	if err != nil {
 		return err
 	}
 
-	query2 := `INSERT INTO history_ticket (id, ticket_id, date, title, "user", created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err = tx.Exec(context.Background(), query2, historyTicket.Id, historyTicket.TicketId,
-		historyTicket.Date, historyTicket.Title, historyTicket.User, historyTicket.CreatedAt, historyTicket.UpdatedAt)
+	err = insertOutboxEvent(ctx, tx, event)
 	if err != nil {
 		return err
 	}
 
-	err = tx.Commit(context.Background())
+	err = tx.Commit(ctx)
 	if err != nil {
 		return err
 	}
@@ -61,9 +82,9 @@ func (r *ticketRepository) CreateTicket(ticket model.Ticket, historyTicket model
 	return nil
 }
 
-func (r *ticketRepository) GetAllTicket() ([]model.Ticket, error) {
-	query := `SELECT * FROM tickets`
-	rows, err := r.db.Query(context.Background(), query)
+func (r *ticketRepository) GetAllTicket(ctx context.Context, filter model.TicketFilter) ([]model.Ticket, error) {
+	query, args := buildTicketListQuery(filter)
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -71,20 +92,23 @@ func (r *ticketRepository) GetAllTicket() ([]model.Ticket, error) {
 
 	var tickets []model.Ticket
 	for rows.Next() {
-		ticket := model.Ticket{}
-		err = rows.Scan(&ticket.Id, &ticket.UserId, &ticket.Title, &ticket.Description, &ticket.Status, &ticket.Point, &ticket.CreatedAt, &ticket.UpdatedAt)
+		ticket, err := scanTicket(rows)
 		if err != nil {
 			return nil, err
 		}
 		tickets = append(tickets, ticket)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return tickets, nil
 }
 
-func (r *ticketRepository) GetHistoryTicketByTicketId(ticketId string) ([]model.HistoryTicket, error) {
-	query := `SELECT * FROM history_ticket WHERE ticket_id = $1`
-	rows, err := r.db.Query(context.Background(), query, ticketId)
+func (r *ticketRepository) GetHistoryTicketByTicketId(ctx context.Context, ticketId string) ([]model.HistoryTicket, error) {
+	query := `SELECT id, ticket_id, event_type, actor_id, actor_name, old_value, new_value, created_at
+		FROM history_ticket WHERE ticket_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.Query(ctx, query, ticketId)
 	if err != nil {
 		return nil, err
 	}
@@ -93,23 +117,28 @@ func (r *ticketRepository) GetHistoryTicketByTicketId(ticketId string) ([]model.
 	var historyTickets []model.HistoryTicket
 	for rows.Next() {
 		historyTicket := model.HistoryTicket{}
-		err = rows.Scan(&historyTicket.Id, &historyTicket.TicketId, &historyTicket.Date, &historyTicket.Title,
-			&historyTicket.User, &historyTicket.CreatedAt, &historyTicket.UpdatedAt)
+		err = rows.Scan(&historyTicket.Id, &historyTicket.TicketId, &historyTicket.EventType, &historyTicket.ActorId,
+			&historyTicket.ActorName, &historyTicket.OldValue, &historyTicket.NewValue, &historyTicket.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
 		historyTickets = append(historyTickets, historyTicket)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return historyTickets, nil
 }
 
-func (r *ticketRepository) GetTicketById(ticketId string) (model.Ticket, error) {
-	query := `SELECT * FROM tickets WHERE id = $1`
-	row := r.db.QueryRow(context.Background(), query, ticketId)
+func (r *ticketRepository) GetTicketById(ctx context.Context, ticketId string) (model.Ticket, error) {
+	query := `SELECT ` + ticketColumns + ` FROM tickets WHERE id = $1 AND deleted_at IS NULL`
+	row := r.db.QueryRow(ctx, query, ticketId)
 
-	ticket := model.Ticket{}
-	err := row.Scan(&ticket.Id, &ticket.UserId, &ticket.Title, &ticket.Description, &ticket.Status, &ticket.Point, &ticket.CreatedAt, &ticket.UpdatedAt)
+	ticket, err := scanTicket(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Ticket{}, ErrTicketNotFound
+	}
 	if err != nil {
 		return model.Ticket{}, err
 	}
@@ -117,93 +146,145 @@ func (r *ticketRepository) GetTicketById(ticketId string) (model.Ticket, error)
 	return ticket, nil
 }
 
-func (r *ticketRepository) UpdateUserTicket(userId, ticketId string, historyTicket model.HistoryTicket) error {
-	tx, err := r.db.Begin(context.Background())
+func (r *ticketRepository) UpdateUserTicket(ctx context.Context, userId, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) (int, error) {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(context.Background())
 
-	query := `UPDATE tickets SET user_id = $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.Exec(context.Background(), query, userId, historyTicket.UpdatedAt, ticketId)
+	query := `UPDATE tickets SET user_id = $1, updated_at = $2, version = version + 1
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+		RETURNING version`
+	var newVersion int
+	err = tx.QueryRow(ctx, query, userId, historyTicket.CreatedAt, ticketId, version).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrVersionConflict
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	query2 := `INSERT INTO history_ticket (id, ticket_id, date, title, "user", created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err = tx.Exec(context.Background(), query2, historyTicket.Id, historyTicket.TicketId,
-		historyTicket.Date, historyTicket.Title, historyTicket.User, historyTicket.CreatedAt, historyTicket.UpdatedAt)
+	err = insertHistoryTicket(ctx, tx, historyTicket)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = tx.Commit(context.Background())
+	err = insertOutboxEvent(ctx, tx, event)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return newVersion, nil
 }
 
-func (r *ticketRepository) UpdateEditTicket(editTicket model.EditTicketRequest, ticketId string, historyTicket model.HistoryTicket) error {
-	tx, err := r.db.Begin(context.Background())
+func (r *ticketRepository) UpdateEditTicket(ctx context.Context, editTicket model.EditTicketRequest, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) (int, error) {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(context.Background())
 
-	query := `UPDATE tickets SET title = $1, description = $2, point = $3, updated_at = $4 WHERE id = $5`
-	_, err = tx.Exec(context.Background(), query, editTicket.Title, editTicket.Description, editTicket.Point, historyTicket.UpdatedAt, ticketId)
+	query := `UPDATE tickets SET title = $1, description = $2, point = $3, updated_at = $4, version = version + 1
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version`
+	var newVersion int
+	err = tx.QueryRow(ctx, query, editTicket.Title, editTicket.Description, editTicket.Point,
+		historyTicket.CreatedAt, ticketId, version).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrVersionConflict
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	query2 := `INSERT INTO history_ticket (id, ticket_id, date, title, "user", created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err = tx.Exec(context.Background(), query2, historyTicket.Id, historyTicket.TicketId,
-		historyTicket.Date, historyTicket.Title, historyTicket.User, historyTicket.CreatedAt, historyTicket.UpdatedAt)
+	err = insertHistoryTicket(ctx, tx, historyTicket)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = tx.Commit(context.Background())
+	err = insertOutboxEvent(ctx, tx, event)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return newVersion, nil
 }
 
-func (r *ticketRepository) UpdateStatusTicket(status, ticketId string, historyTicket model.HistoryTicket) error {
-	tx, err := r.db.Begin(context.Background())
+func (r *ticketRepository) UpdateStatusTicket(ctx context.Context, status, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) (int, error) {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(context.Background())
 
-	query := `UPDATE tickets SET status = $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.Exec(context.Background(), query, status, historyTicket.UpdatedAt, ticketId)
+	query := `UPDATE tickets SET status = $1, updated_at = $2, version = version + 1
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+		RETURNING version`
+	var newVersion int
+	err = tx.QueryRow(ctx, query, status, historyTicket.CreatedAt, ticketId, version).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrVersionConflict
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	query2 := `INSERT INTO history_ticket (id, ticket_id, date, title, "user", created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err = tx.Exec(context.Background(), query2, historyTicket.Id, historyTicket.TicketId,
-		historyTicket.Date, historyTicket.Title, historyTicket.User, historyTicket.CreatedAt, historyTicket.UpdatedAt)
+	err = insertHistoryTicket(ctx, tx, historyTicket)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = tx.Commit(context.Background())
+	err = insertOutboxEvent(ctx, tx, event)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return newVersion, nil
+}
+
+func (r *ticketRepository) CountTicketGroupByStatus(ctx context.Context, userId string) ([]model.CountTicket, error) {
+	query := `SELECT status, COUNT(*) FROM tickets WHERE user_id = $1 AND deleted_at IS NULL GROUP BY status`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tickets []model.CountTicket
+	for rows.Next() {
+		ticket := model.CountTicket{}
+		err = rows.Scan(&ticket.Status, &ticket.Count)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
 }
 
-func (r *ticketRepository) CountTicketGroupByStatus(userId string) ([]model.CountTicket, error) {
-	query := `SELECT status, COUNT(*) FROM tickets WHERE user_id = $1 GROUP BY status`
-	rows, err := r.db.Query(context.Background(), query, userId)
+func (r *ticketRepository) CountAllTicketGroupByStatus(ctx context.Context) ([]model.CountTicket, error) {
+	query := `SELECT status, COUNT(*) FROM tickets WHERE deleted_at IS NULL GROUP BY status`
+	rows, err := r.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -218,13 +299,16 @@ func (r *ticketRepository) CountTicketGroupByStatus(userId string) ([]model.Coun
 		}
 		tickets = append(tickets, ticket)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return tickets, nil
 }
 
-func (r *ticketRepository) SumTicketGroupByStatus(userId string) ([]model.SumPoint, error) {
-	query := `SELECT status, SUM(point) FROM tickets WHERE user_id = $1 GROUP BY status`
-	rows, err := r.db.Query(context.Background(), query, userId)
+func (r *ticketRepository) SumTicketGroupByStatus(ctx context.Context, userId string) ([]model.SumPoint, error) {
+	query := `SELECT status, SUM(point) FROM tickets WHERE user_id = $1 AND deleted_at IS NULL GROUP BY status`
+	rows, err := r.db.Query(ctx, query, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -239,13 +323,16 @@ func (r *ticketRepository) SumTicketGroupByStatus(userId string) ([]model.SumPoi
 		}
 		tickets = append(tickets, ticket)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return tickets, nil
 }
 
-func (r *ticketRepository) CountTicketDone(userId string) (int, error) {
-	query := `SELECT COUNT(*) FROM tickets WHERE user_id = $1 AND status = 'done'`
-	row := r.db.QueryRow(context.Background(), query, userId)
+func (r *ticketRepository) CountTicketCompleted(ctx context.Context, userId string, completedStatuses []string) (int, error) {
+	query := `SELECT COUNT(*) FROM tickets WHERE user_id = $1 AND deleted_at IS NULL AND status = ANY($2)`
+	row := r.db.QueryRow(ctx, query, userId, completedStatuses)
 
 	var count int
 	err := row.Scan(&count)
@@ -256,9 +343,9 @@ func (r *ticketRepository) CountTicketDone(userId string) (int, error) {
 	return count, nil
 }
 
-func (r *ticketRepository) CountTicket(userId string) (int, error) {
-	query := `SELECT COUNT(*) FROM tickets WHERE user_id = $1`
-	row := r.db.QueryRow(context.Background(), query, userId)
+func (r *ticketRepository) CountTicket(ctx context.Context, userId string) (int, error) {
+	query := `SELECT COUNT(*) FROM tickets WHERE user_id = $1 AND deleted_at IS NULL`
+	row := r.db.QueryRow(ctx, query, userId)
 
 	var count int
 	err := row.Scan(&count)
@@ -269,9 +356,9 @@ func (r *ticketRepository) CountTicket(userId string) (int, error) {
 	return count, nil
 }
 
-func (r *ticketRepository) SumPointTicketDone(userId string) (int, error) {
-	query := `SELECT SUM(point) FROM tickets WHERE user_id = $1 AND status = 'done'`
-	row := r.db.QueryRow(context.Background(), query, userId)
+func (r *ticketRepository) SumPointTicketCompleted(ctx context.Context, userId string, completedStatuses []string) (int, error) {
+	query := `SELECT COALESCE(SUM(point), 0) FROM tickets WHERE user_id = $1 AND deleted_at IS NULL AND status = ANY($2)`
+	row := r.db.QueryRow(ctx, query, userId, completedStatuses)
 
 	var sum int
 	err := row.Scan(&sum)
@@ -282,9 +369,9 @@ func (r *ticketRepository) SumPointTicketDone(userId string) (int, error) {
 	return sum, nil
 }
 
-func (r *ticketRepository) SumPointTicket(userId string) (int, error) {
-	query := `SELECT SUM(point) FROM tickets WHERE user_id = $1`
-	row := r.db.QueryRow(context.Background(), query, userId)
+func (r *ticketRepository) SumPointTicket(ctx context.Context, userId string) (int, error) {
+	query := `SELECT COALESCE(SUM(point), 0) FROM tickets WHERE user_id = $1 AND deleted_at IS NULL`
+	row := r.db.QueryRow(ctx, query, userId)
 
 	var sum int
 	err := row.Scan(&sum)
@@ -294,3 +381,196 @@ func (r *ticketRepository) SumPointTicket(userId string) (int, error) {
 
 	return sum, nil
 }
+
+func (r *ticketRepository) GetDeletedTicketById(ctx context.Context, ticketId string) (model.Ticket, error) {
+	query := `SELECT ` + ticketColumns + ` FROM tickets WHERE id = $1 AND deleted_at IS NOT NULL`
+	row := r.db.QueryRow(ctx, query, ticketId)
+
+	ticket, err := scanTicket(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Ticket{}, ErrTicketNotFound
+	}
+	if err != nil {
+		return model.Ticket{}, err
+	}
+
+	return ticket, nil
+}
+
+func (r *ticketRepository) DeleteTicket(ctx context.Context, ticketId string, version int, historyTicket model.HistoryTicket, event model.Event) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `UPDATE tickets SET deleted_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, historyTicket.CreatedAt, ticketId, version)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrVersionConflict
+	}
+
+	err = insertHistoryTicket(ctx, tx, historyTicket)
+	if err != nil {
+		return err
+	}
+
+	err = insertOutboxEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *ticketRepository) RestoreTicket(ctx context.Context, ticketId string, historyTicket model.HistoryTicket, event model.Event) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `UPDATE tickets SET deleted_at = NULL, updated_at = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NOT NULL`
+	tag, err := tx.Exec(ctx, query, historyTicket.CreatedAt, ticketId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTicketNotFound
+	}
+
+	err = insertHistoryTicket(ctx, tx, historyTicket)
+	if err != nil {
+		return err
+	}
+
+	err = insertOutboxEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *ticketRepository) PurgeDeletedTickets(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	purgeable := `SELECT id FROM tickets WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	_, err = tx.Exec(ctx, `DELETE FROM comments WHERE ticket_id IN (`+purgeable+`)`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM history_ticket WHERE ticket_id IN (`+purgeable+`)`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM tickets WHERE deleted_at IS NOT NULL AND deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func insertHistoryTicket(ctx context.Context, tx pgx.Tx, historyTicket model.HistoryTicket) error {
+	query := `INSERT INTO history_ticket (id, ticket_id, event_type, actor_id, actor_name, old_value, new_value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.Exec(ctx, query, historyTicket.Id, historyTicket.TicketId, historyTicket.EventType,
+		historyTicket.ActorId, historyTicket.ActorName, historyTicket.OldValue, historyTicket.NewValue, historyTicket.CreatedAt)
+
+	return err
+}
+
+func buildTicketListQuery(filter model.TicketFilter) (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(filter.Statuses)+")")
+	}
+	if filter.AssigneeId != nil {
+		conditions = append(conditions, "user_id = "+arg(*filter.AssigneeId))
+	}
+	if filter.MinPoint != nil {
+		conditions = append(conditions, "point >= "+arg(*filter.MinPoint))
+	}
+	if filter.MaxPoint != nil {
+		conditions = append(conditions, "point <= "+arg(*filter.MaxPoint))
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(*filter.CreatedTo))
+	}
+	if filter.UpdatedFrom != nil {
+		conditions = append(conditions, "updated_at >= "+arg(*filter.UpdatedFrom))
+	}
+	if filter.UpdatedTo != nil {
+		conditions = append(conditions, "updated_at < "+arg(*filter.UpdatedTo))
+	}
+	if filter.Title != "" {
+		conditions = append(conditions, `title ILIKE '%' || `+arg(escapeLike(filter.Title))+` || '%'`)
+	}
+
+	sortColumn := "created_at"
+	switch filter.Sort {
+	case "updated_at", "point":
+		sortColumn = filter.Sort
+	}
+	direction, comparison := "DESC", "<"
+	if filter.Order == "asc" {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.Cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			sortColumn, comparison, arg(filter.Cursor.Value), arg(filter.Cursor.Id)))
+	}
+
+	query := `SELECT ` + ticketColumns + ` FROM tickets WHERE ` + strings.Join(conditions, " AND ")
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", sortColumn, direction, direction, arg(filter.Limit))
+
+	return query, args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func scanTicket(row pgx.Row) (model.Ticket, error) {
+	ticket := model.Ticket{}
+	err := row.Scan(&ticket.Id, &ticket.UserId, &ticket.ReporterId, &ticket.Title, &ticket.Description, &ticket.Status,
+		&ticket.Point, &ticket.Version, &ticket.CreatedAt, &ticket.UpdatedAt, &ticket.DeletedAt)
+
+	return ticket, err
+}
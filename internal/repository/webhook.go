@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sort"
+	"time"
+)
+
+const (
+	webhookColumns  = `id, url, secret, event_types, active, created_by, created_at, updated_at`
+	deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, response_status, last_error,
+		redelivery_of, next_attempt_at, created_at, delivered_at`
+)
+
+var (
+	ErrWebhookNotFound  = apperror.NotFound("webhook not found", nil)
+	ErrDeliveryNotFound = apperror.NotFound("webhook delivery not found", nil)
+)
+
+type webhookRepository struct {
+	db *pgxpool.Pool
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook model.Webhook) error
+	GetWebhooks(ctx context.Context) ([]model.Webhook, error)
+	GetWebhookById(ctx context.Context, webhookId string) (model.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook model.Webhook) error
+	DeleteWebhook(ctx context.Context, webhookId string) error
+	EnqueueDeliveries(ctx context.Context, event model.Event) error
+	GetDeliveriesByWebhookId(ctx context.Context, webhookId string, limit int) ([]model.WebhookDelivery, error)
+	GetDeliveryById(ctx context.Context, webhookId, deliveryId string) (model.WebhookDelivery, error)
+	CreateDelivery(ctx context.Context, delivery model.WebhookDelivery) error
+	ClaimPendingDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.PendingDelivery, error)
+	CompleteDeliveries(ctx context.Context, maxAttempts int, results []model.DeliveryResult) error
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook model.Webhook) error {
+	query := `INSERT INTO webhooks (` + webhookColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.Exec(ctx, query, webhook.Id, webhook.URL, webhook.Secret, webhook.EventTypes,
+		webhook.Active, webhook.CreatedBy, webhook.CreatedAt, webhook.UpdatedAt)
+
+	return err
+}
+
+func (r *webhookRepository) GetWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at, id`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []model.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *webhookRepository) GetWebhookById(ctx context.Context, webhookId string) (model.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	row := r.db.QueryRow(ctx, query, webhookId)
+
+	webhook, err := scanWebhook(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Webhook{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (r *webhookRepository) UpdateWebhook(ctx context.Context, webhook model.Webhook) error {
+	query := `UPDATE webhooks SET url = $1, event_types = $2, active = $3, updated_at = $4 WHERE id = $5`
+	tag, err := r.db.Exec(ctx, query, webhook.URL, webhook.EventTypes, webhook.Active,
+		webhook.UpdatedAt, webhook.Id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, webhookId string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// EnqueueDeliveries creates one pending delivery per active webhook subscribed
+// to the event. It is idempotent per event so the outbox relay may safely
+// publish the same event twice.
+func (r *webhookRepository) EnqueueDeliveries(ctx context.Context, event model.Event) error {
+	query := `INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT gen_random_uuid(), id, $1, $2, $3, $4, now(), now() FROM webhooks
+		WHERE active AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+		ON CONFLICT (webhook_id, event_id) WHERE redelivery_of IS NULL DO NOTHING`
+	_, err := r.db.Exec(ctx, query, event.Id, event.Type, event, model.DeliveryPending)
+
+	return err
+}
+
+func (r *webhookRepository) GetDeliveriesByWebhookId(ctx context.Context, webhookId string, limit int) ([]model.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1
+		ORDER BY created_at DESC, id DESC LIMIT $2`
+	rows, err := r.db.Query(ctx, query, webhookId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookRepository) GetDeliveryById(ctx context.Context, webhookId, deliveryId string) (model.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 AND id = $2`
+	row := r.db.QueryRow(ctx, query, webhookId, deliveryId)
+
+	delivery, err := scanDelivery(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	return delivery, nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, redelivery_of,
+		next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := r.db.Exec(ctx, query, delivery.Id, delivery.WebhookId, delivery.EventId,
+		delivery.EventType, delivery.Payload, delivery.Status, delivery.RedeliveryOf, delivery.NextAttemptAt,
+		delivery.CreatedAt)
+
+	return err
+}
+
+// ClaimPendingDeliveries leases up to limit due deliveries for lease and
+// returns them, oldest due first, with their webhooks. Like the outbox the
+// claim commits immediately, so nothing stays locked while the HTTP requests
+// run. Deliveries of inactive webhooks are left pending until the webhook is
+// reactivated.
+func (r *webhookRepository) ClaimPendingDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.PendingDelivery, error) {
+	query := `UPDATE webhook_deliveries SET locked_until = now() + $3::interval WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= now() AND (locked_until IS NULL OR locked_until <= now())
+			AND EXISTS (SELECT 1 FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id AND webhooks.active)
+			ORDER BY next_attempt_at, id LIMIT $2 FOR UPDATE SKIP LOCKED)
+		RETURNING ` + deliveryColumns
+	rows, err := r.db.Query(ctx, query, model.DeliveryPending, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.WebhookDelivery, error) {
+		return scanDelivery(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	webhookIds := make([]uuid.UUID, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		webhookIds = append(webhookIds, delivery.WebhookId)
+	}
+	rows, err = r.db.Query(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ANY($1) AND active`, webhookIds)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Webhook, error) {
+		return scanWebhook(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	webhooksById := make(map[uuid.UUID]model.Webhook, len(webhooks))
+	for _, webhook := range webhooks {
+		webhooksById[webhook.Id] = webhook
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].NextAttemptAt.Equal(deliveries[j].NextAttemptAt) {
+			return deliveries[i].Id.String() < deliveries[j].Id.String()
+		}
+		return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+	})
+
+	pending := make([]model.PendingDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		// A webhook deleted since the claim takes its deliveries with it; one
+		// deactivated since keeps them leased until the lease runs out.
+		webhook, ok := webhooksById[delivery.WebhookId]
+		if !ok {
+			continue
+		}
+		pending = append(pending, model.PendingDelivery{Webhook: webhook, Delivery: delivery})
+	}
+
+	return pending, nil
+}
+
+// CompleteDeliveries records a batch of attempts in one short transaction and
+// releases the leases. Failed deliveries are retried with exponential backoff,
+// randomized between half and all of the step so that deliveries failing
+// together don't retry together, until maxAttempts is reached, after which
+// they are marked failed.
+func (r *webhookRepository) CompleteDeliveries(ctx context.Context, maxAttempts int, results []model.DeliveryResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	for _, result := range results {
+		var responseStatus *int
+		if result.ResponseStatus != 0 {
+			responseStatus = &result.ResponseStatus
+		}
+
+		switch {
+		case result.Skipped:
+			_, err = tx.Exec(ctx, `UPDATE webhook_deliveries SET locked_until = NULL WHERE id = $1 AND status = $2`,
+				result.DeliveryId, model.DeliveryPending)
+		case result.Err != nil:
+			_, err = tx.Exec(ctx, `UPDATE webhook_deliveries SET attempts = attempts + 1,
+				status = CASE WHEN attempts + 1 >= $1 THEN $2 ELSE status END,
+				response_status = $3, last_error = $4, locked_until = NULL,
+				next_attempt_at = now() + make_interval(secs => LEAST(10 * power(2, attempts), 3600) * (0.5 + random() / 2))
+				WHERE id = $5 AND status = $6`, maxAttempts, model.DeliveryFailed, responseStatus,
+				result.Err.Error(), result.DeliveryId, model.DeliveryPending)
+		default:
+			_, err = tx.Exec(ctx, `UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1,
+				response_status = $2, last_error = NULL, locked_until = NULL, delivered_at = now()
+				WHERE id = $3 AND status = $4`, model.DeliverySucceeded, responseStatus, result.DeliveryId,
+				model.DeliveryPending)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func scanWebhook(row pgx.Row) (model.Webhook, error) {
+	webhook := model.Webhook{}
+	err := row.Scan(&webhook.Id, &webhook.URL, &webhook.Secret, &webhook.EventTypes, &webhook.Active,
+		&webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt)
+
+	return webhook, err
+}
+
+func scanDelivery(row pgx.Row) (model.WebhookDelivery, error) {
+	delivery := model.WebhookDelivery{}
+	err := row.Scan(&delivery.Id, &delivery.WebhookId, &delivery.EventId, &delivery.EventType, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.RedeliveryOf,
+		&delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.DeliveredAt)
+
+	return delivery, err
+}
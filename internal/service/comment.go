@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/directory"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/repository"
+	"github.com/google/uuid"
+	"time"
+)
+
+type commentService struct {
+	commentRepository repository.CommentRepository
+	ticketRepository  repository.TicketRepository
+	users             directory.UserDirectory
+}
+
+type CommentService interface {
+	CreateComment(ctx context.Context, ticketId string, principal model.Principal, comment model.CommentRequest) (model.CommentResponse, error)
+	GetComments(ctx context.Context, ticketId string, limit int, cursor *model.CommentCursor) ([]model.CommentResponse, string, error)
+	UpdateComment(ctx context.Context, ticketId, commentId string, principal model.Principal, comment model.CommentRequest) (model.CommentResponse, error)
+	DeleteComment(ctx context.Context, ticketId, commentId string, principal model.Principal) error
+}
+
+type commentedValue struct {
+	CommentId string `json:"comment_id"`
+}
+
+func NewCommentService(commentRepository repository.CommentRepository, ticketRepository repository.TicketRepository,
+	users directory.UserDirectory) CommentService {
+	return &commentService{
+		commentRepository: commentRepository,
+		ticketRepository:  ticketRepository,
+		users:             users,
+	}
+}
+
+func (s *commentService) CreateComment(ctx context.Context, ticketId string, principal model.Principal, comment model.CommentRequest) (model.CommentResponse, error) {
+	if err := validateTicketId(ticketId); err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	ticket, err := s.ticketRepository.GetTicketById(ctx, ticketId)
+	if err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	author, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	authorId, err := uuid.Parse(author.Id)
+	if err != nil {
+		return model.CommentResponse{}, apperror.Upstream("user service returned an invalid user id", err)
+	}
+
+	now := time.Now()
+	c := model.Comment{
+		Id:        uuid.New(),
+		TicketId:  ticket.Id,
+		AuthorId:  authorId,
+		Body:      comment.Body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	ht, err := newHistoryTicket(ticket.Id, model.HistoryEventCommented, author, nil, commentedValue{CommentId: c.Id.String()})
+	if err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	if err := s.commentRepository.CreateComment(ctx, c, ht); err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	return commentResponse(c, author.Name, author.ProfilePic), nil
+}
+
+func (s *commentService) GetComments(ctx context.Context, ticketId string, limit int, cursor *model.CommentCursor) ([]model.CommentResponse, string, error) {
+	if err := validateTicketId(ticketId); err != nil {
+		return nil, "", err
+	}
+
+	if _, err := s.ticketRepository.GetTicketById(ctx, ticketId); err != nil {
+		return nil, "", err
+	}
+
+	comments, err := s.commentRepository.GetCommentsByTicketId(ctx, ticketId, limit+1, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(comments) > limit {
+		comments = comments[:limit]
+		nextCursor = helper.EncodeCommentCursor(comments[limit-1])
+	}
+
+	authorIds := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, c := range comments {
+		if !seen[c.AuthorId.String()] {
+			seen[c.AuthorId.String()] = true
+			authorIds = append(authorIds, c.AuthorId.String())
+		}
+	}
+
+	authors, err := s.users.GetUsersByIds(ctx, authorIds)
+	authorsUnknown, err := degradeUserLookup(ctx, err)
+	if err != nil {
+		return nil, "", err
+	}
+
+	commentResponses := make([]model.CommentResponse, 0)
+	for _, c := range comments {
+		name, profilePic := "", ""
+		if author, ok := authors[c.AuthorId.String()]; ok {
+			name, profilePic = author.Name, author.ProfilePic
+		}
+		response := commentResponse(c, name, profilePic)
+		response.AuthorUnknown = authorsUnknown
+		commentResponses = append(commentResponses, response)
+	}
+
+	return commentResponses, nextCursor, nil
+}
+
+func (s *commentService) UpdateComment(ctx context.Context, ticketId, commentId string, principal model.Principal, comment model.CommentRequest) (model.CommentResponse, error) {
+	c, author, err := s.authorizeAuthor(ctx, ticketId, commentId, principal)
+	if err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	c.Body = comment.Body
+	c.UpdatedAt = time.Now()
+	if err := s.commentRepository.UpdateComment(ctx, c); err != nil {
+		return model.CommentResponse{}, err
+	}
+
+	return commentResponse(c, author.Name, author.ProfilePic), nil
+}
+
+func (s *commentService) DeleteComment(ctx context.Context, ticketId, commentId string, principal model.Principal) error {
+	if _, _, err := s.authorizeAuthor(ctx, ticketId, commentId, principal); err != nil {
+		return err
+	}
+
+	return s.commentRepository.DeleteComment(ctx, ticketId, commentId)
+}
+
+// authorizeAuthor loads the comment and checks that the caller wrote it.
+// Comments on a deleted ticket are not found, like the ticket itself.
+func (s *commentService) authorizeAuthor(ctx context.Context, ticketId, commentId string, principal model.Principal) (model.Comment, *grpcserver.UserProto, error) {
+	if err := validateTicketId(ticketId); err != nil {
+		return model.Comment{}, nil, err
+	}
+	if _, err := uuid.Parse(commentId); err != nil {
+		return model.Comment{}, nil, apperror.InvalidArgument("invalid comment id", err)
+	}
+
+	if _, err := s.ticketRepository.GetTicketById(ctx, ticketId); err != nil {
+		return model.Comment{}, nil, err
+	}
+
+	c, err := s.commentRepository.GetCommentById(ctx, ticketId, commentId)
+	if err != nil {
+		return model.Comment{}, nil, err
+	}
+
+	actor, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return model.Comment{}, nil, err
+	}
+
+	if actor.Id != c.AuthorId.String() {
+		return model.Comment{}, nil, apperror.Forbidden("only the author can change this comment", nil)
+	}
+
+	return c, actor, nil
+}
+
+func commentResponse(c model.Comment, author, profilePic string) model.CommentResponse {
+	return model.CommentResponse{
+		Id:         c.Id,
+		TicketId:   c.TicketId,
+		AuthorId:   c.AuthorId,
+		Author:     author,
+		ProfilePic: profilePic,
+		Body:       c.Body,
+		Edited:     c.UpdatedAt.After(c.CreatedAt),
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/google/uuid"
+)
+
+// newTicketEvent builds the outbox event published for a history entry so the
+// event and the history row describe the same change.
+func newTicketEvent(eventType string, historyTicket model.HistoryTicket) (model.Event, error) {
+	payload, err := json.Marshal(model.EventPayload{
+		ActorId:   historyTicket.ActorId,
+		ActorName: historyTicket.ActorName,
+		OldValue:  historyTicket.OldValue,
+		NewValue:  historyTicket.NewValue,
+	})
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	return model.Event{
+		Id:         uuid.New(),
+		Type:       eventType,
+		TicketId:   historyTicket.TicketId,
+		Payload:    payload,
+		OccurredAt: historyTicket.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/google/uuid"
+	"time"
+)
+
+type statusValue struct {
+	Status string `json:"status"`
+}
+
+type assigneeValue struct {
+	UserId string `json:"user_id"`
+	Name   string `json:"name,omitempty"`
+}
+
+type createdValue struct {
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Point  int    `json:"point"`
+}
+
+type editedValue struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Point       *int    `json:"point,omitempty"`
+}
+
+// diffEdit returns the before and after values of the fields an edit changes.
+func diffEdit(ticket model.Ticket, editTicket model.EditTicketRequest) (editedValue, editedValue) {
+	oldValue, newValue := editedValue{}, editedValue{}
+	if ticket.Title != editTicket.Title {
+		oldValue.Title, newValue.Title = &ticket.Title, &editTicket.Title
+	}
+	if ticket.Description != editTicket.Description {
+		oldValue.Description, newValue.Description = &ticket.Description, &editTicket.Description
+	}
+	if ticket.Point != editTicket.Point {
+		oldValue.Point, newValue.Point = &ticket.Point, &editTicket.Point
+	}
+
+	return oldValue, newValue
+}
+
+func editChanges(ht model.HistoryTicket) []model.FieldChange {
+	oldValue, newValue := editedValue{}, editedValue{}
+	_ = json.Unmarshal(ht.OldValue, &oldValue)
+	_ = json.Unmarshal(ht.NewValue, &newValue)
+
+	changes := make([]model.FieldChange, 0)
+	if newValue.Title != nil {
+		changes = append(changes, model.FieldChange{Field: "title", OldValue: oldValue.Title, NewValue: newValue.Title})
+	}
+	if newValue.Description != nil {
+		changes = append(changes, model.FieldChange{Field: "description", OldValue: oldValue.Description, NewValue: newValue.Description})
+	}
+	if newValue.Point != nil {
+		changes = append(changes, model.FieldChange{Field: "point", OldValue: oldValue.Point, NewValue: newValue.Point})
+	}
+
+	return changes
+}
+
+func newHistoryTicket(ticketId uuid.UUID, eventType model.HistoryEventType, actor *grpcserver.UserProto, oldValue, newValue interface{}) (model.HistoryTicket, error) {
+	ht := model.HistoryTicket{
+		Id:        uuid.New(),
+		TicketId:  ticketId,
+		EventType: eventType,
+		ActorName: actor.Name,
+		CreatedAt: time.Now(),
+	}
+
+	if actorId, err := uuid.Parse(actor.Id); err == nil {
+		ht.ActorId = &actorId
+	}
+
+	var err error
+	if oldValue != nil {
+		if ht.OldValue, err = json.Marshal(oldValue); err != nil {
+			return model.HistoryTicket{}, err
+		}
+	}
+	if newValue != nil {
+		if ht.NewValue, err = json.Marshal(newValue); err != nil {
+			return model.HistoryTicket{}, err
+		}
+	}
+
+	return ht, nil
+}
+
+func (s *ticketService) historyTicketResponses(ctx context.Context, historyTickets []model.HistoryTicket) ([]model.HistoryTicketResponse, error) {
+	actorIds := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, ht := range historyTickets {
+		if ht.ActorId == nil || seen[ht.ActorId.String()] {
+			continue
+		}
+		seen[ht.ActorId.String()] = true
+		actorIds = append(actorIds, ht.ActorId.String())
+	}
+
+	// Without the user service the names recorded with each entry are used.
+	actors, err := s.users.GetUsersByIds(ctx, actorIds)
+	if _, err = degradeUserLookup(ctx, err); err != nil {
+		return nil, err
+	}
+
+	historyTicketResponses := make([]model.HistoryTicketResponse, 0)
+	for _, ht := range historyTickets {
+		actorName := ht.ActorName
+		if ht.ActorId != nil {
+			if actor, ok := actors[ht.ActorId.String()]; ok {
+				actorName = actor.Name
+			}
+		}
+
+		htr := model.HistoryTicketResponse{
+			Date:      ht.CreatedAt.Format("02 Jan 2006"),
+			Title:     renderHistoryTitle(ht, actorName),
+			User:      actorName,
+			EventType: ht.EventType,
+			ActorId:   ht.ActorId,
+			OldValue:  ht.OldValue,
+			NewValue:  ht.NewValue,
+			CreatedAt: ht.CreatedAt,
+		}
+		if ht.EventType == model.HistoryEventEdited {
+			htr.Changes = editChanges(ht)
+		}
+		historyTicketResponses = append(historyTicketResponses, htr)
+	}
+
+	return historyTicketResponses, nil
+}
+
+func renderHistoryTitle(ht model.HistoryTicket, actorName string) string {
+	switch ht.EventType {
+	case model.HistoryEventCreated:
+		return "Ticket Created"
+	case model.HistoryEventStatusChanged:
+		v := statusValue{}
+		_ = json.Unmarshal(ht.NewValue, &v)
+		return fmt.Sprintf("%s Change status to %s", actorName, v.Status)
+	case model.HistoryEventAssigneeChanged:
+		v := assigneeValue{}
+		_ = json.Unmarshal(ht.NewValue, &v)
+		return fmt.Sprintf("Change Assignees to %s", v.Name)
+	case model.HistoryEventEdited:
+		return fmt.Sprintf("Edited by %s", actorName)
+	case model.HistoryEventCommented:
+		return fmt.Sprintf("%s commented", actorName)
+	case model.HistoryEventDeleted:
+		return fmt.Sprintf("Deleted by %s", actorName)
+	case model.HistoryEventRestored:
+		return fmt.Sprintf("Restored by %s", actorName)
+	default:
+		v := struct {
+			Title string `json:"title"`
+		}{}
+		_ = json.Unmarshal(ht.NewValue, &v)
+		if v.Title != "" {
+			return v.Title
+		}
+		return string(ht.EventType)
+	}
+}
@@ -2,241 +2,451 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/broker"
+	"github.com/gemm123/vkrf-ticket/internal/directory"
 	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
 	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/policy"
 	"github.com/gemm123/vkrf-ticket/internal/repository"
+	"github.com/gemm123/vkrf-ticket/internal/workflow"
 	"github.com/google/uuid"
-	"google.golang.org/grpc"
-	"log"
+	"log/slog"
 	"time"
 )
 
 type ticketService struct {
 	ticketRepository repository.TicketRepository
-	conn             *grpc.ClientConn
+	users            directory.UserDirectory
+	policy           *policy.Policy
+	workflow         workflow.Workflow
+	retention        time.Duration
+	broker           *broker.Broker
 }
 
 type TicketService interface {
-	CreateTicket(ticket model.TicketRequest, email string) error
-	GetAllTicket() ([]model.TicketResponse, error)
-	GetDetailTicket(ticketId string) (model.DetailTicketResponse, error)
-	UpdateUserTicket(emailAssignee, ticketId, email string) error
-	UpdateEditTicket(ticketId, email string, editTicket model.EditTicketRequest) error
-	UpdateStatusTicket(ticketId, email, status string) error
-	Summary(email string) ([]model.SummaryResponse, error)
-	Performance(email string) (model.Performance, error)
+	CreateTicket(ctx context.Context, ticket model.TicketRequest, email string) (string, error)
+	GetAllTicket(ctx context.Context, filter model.TicketFilter) ([]model.TicketResponse, string, error)
+	GetDetailTicket(ctx context.Context, ticketId string) (model.DetailTicketResponse, error)
+	UpdateUserTicket(ctx context.Context, emailAssignee, ticketId string, match model.VersionMatch, principal model.Principal) (int, error)
+	UpdateEditTicket(ctx context.Context, ticketId string, match model.VersionMatch, principal model.Principal, editTicket model.EditTicketRequest) (int, error)
+	UpdateStatusTicket(ctx context.Context, ticketId string, match model.VersionMatch, principal model.Principal, status string) (int, error)
+	Summary(ctx context.Context, email string) ([]model.SummaryResponse, error)
+	Performance(ctx context.Context, email string) (model.Performance, error)
+	DeleteTicket(ctx context.Context, ticketId string, match model.VersionMatch, principal model.Principal) error
+	RestoreTicket(ctx context.Context, ticketId string, principal model.Principal) error
+	PurgeDeletedTickets(ctx context.Context, principal model.Principal) (int64, error)
 }
 
-func NewTicketService(ticketRepository repository.TicketRepository, conn *grpc.ClientConn) TicketService {
+func NewTicketService(ticketRepository repository.TicketRepository, users directory.UserDirectory, policy *policy.Policy, workflow workflow.Workflow, retention time.Duration, broker *broker.Broker) TicketService {
 	return &ticketService{
 		ticketRepository: ticketRepository,
-		conn:             conn,
+		users:            users,
+		policy:           policy,
+		workflow:         workflow,
+		retention:        retention,
+		broker:           broker,
 	}
 }
 
-func (s *ticketService) CreateTicket(ticket model.TicketRequest, email string) error {
-	c := grpcserver.NewUserServiceClient(s.conn)
-	userRequest := grpcserver.GetUserByEmailRequest{
-		Email: email,
+func (s *ticketService) CreateTicket(ctx context.Context, ticket model.TicketRequest, email string) (string, error) {
+	if err := s.workflow.CheckInitial(ticket.Status); err != nil {
+		return "", apperror.Unprocessable(err.Error(), err)
 	}
-	resp, err := c.GetUserByEmail(context.Background(), &userRequest)
+
+	user, err := lookupCaller(ctx, s.users, email)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		return err
+		return "", err
 	}
 
-	userId, _ := uuid.Parse(resp.User.Id)
+	userId, _ := uuid.Parse(user.Id)
 	t := model.Ticket{
 		Id:          uuid.New(),
 		UserId:      userId,
+		ReporterId:  userId,
 		Title:       ticket.Title,
 		Description: ticket.Description,
 		Status:      ticket.Status,
 		Point:       ticket.Point,
+		Version:     1,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	ht := model.HistoryTicket{
-		Id:        uuid.New(),
-		TicketId:  t.Id,
-		Date:      t.CreatedAt.Format("02 Jan 2006"),
-		Title:     "Ticket Created",
-		User:      resp.User.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	ht, err := newHistoryTicket(t.Id, model.HistoryEventCreated, user, nil, createdValue{
+		Title:  t.Title,
+		Status: t.Status,
+		Point:  t.Point,
+	})
+	if err != nil {
+		return "", err
 	}
 
-	if err := s.ticketRepository.CreateTicket(t, ht); err != nil {
-		return err
+	event, err := newTicketEvent(model.EventTicketCreated, ht)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	if err := s.ticketRepository.CreateTicket(ctx, t, ht, event); err != nil {
+		return "", err
+	}
+	s.broker.Publish(event, t.UserId.String(), t.Status)
+
+	return t.Id.String(), nil
 }
 
-func (s *ticketService) GetAllTicket() ([]model.TicketResponse, error) {
-	tickets, err := s.ticketRepository.GetAllTicket()
+func (s *ticketService) GetAllTicket(ctx context.Context, filter model.TicketFilter) ([]model.TicketResponse, string, error) {
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	tickets, err := s.ticketRepository.GetAllTicket(ctx, filter)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	ticketResponses := make([]model.TicketResponse, 0)
+	nextCursor := ""
+	if len(tickets) > limit {
+		tickets = tickets[:limit]
+		nextCursor = helper.EncodeTicketCursor(filter, tickets[limit-1])
+	}
+
+	userIds := make([]string, 0)
+	seen := make(map[string]bool)
 	for _, ticket := range tickets {
-		c := grpcserver.NewUserServiceClient(s.conn)
-		userRequest := grpcserver.GetUserByUserIdRequest{
-			UserId: ticket.UserId.String(),
-		}
-		resp, err := c.GetUserByUserId(context.Background(), &userRequest)
-		if err != nil {
-			log.Printf("Error: %v", err)
-			return nil, err
+		userId := ticket.UserId.String()
+		if !seen[userId] {
+			seen[userId] = true
+			userIds = append(userIds, userId)
 		}
+	}
+
+	users, err := s.users.GetUsersByIds(ctx, userIds)
+	usersUnknown, err := degradeUserLookup(ctx, err)
+	if err != nil {
+		return nil, "", err
+	}
 
+	ticketResponses := make([]model.TicketResponse, 0)
+	for _, ticket := range tickets {
 		ticketResponse := model.TicketResponse{
 			Id:          ticket.Id,
 			Title:       ticket.Title,
 			Description: ticket.Description,
 			Status:      ticket.Status,
 			Point:       ticket.Point,
-			User:        resp.User.Name,
-			ProfilePic:  resp.User.ProfilePic,
+		}
+		if user, ok := users[ticket.UserId.String()]; ok {
+			ticketResponse.User = user.Name
+			ticketResponse.ProfilePic = user.ProfilePic
+		} else if usersUnknown {
+			ticketResponse.UserUnknown = true
 		}
 
 		ticketResponses = append(ticketResponses, ticketResponse)
 	}
 
-	return ticketResponses, nil
+	return ticketResponses, nextCursor, nil
 }
 
-func (s *ticketService) GetDetailTicket(ticketId string) (model.DetailTicketResponse, error) {
-	ticket, err := s.ticketRepository.GetTicketById(ticketId)
-	if err != nil {
+func (s *ticketService) GetDetailTicket(ctx context.Context, ticketId string) (model.DetailTicketResponse, error) {
+	if err := validateTicketId(ticketId); err != nil {
 		return model.DetailTicketResponse{}, err
 	}
 
-	c := grpcserver.NewUserServiceClient(s.conn)
-	userRequest := grpcserver.GetUserByUserIdRequest{
-		UserId: ticket.UserId.String(),
+	ticket, err := s.ticketRepository.GetTicketById(ctx, ticketId)
+	if err != nil {
+		return model.DetailTicketResponse{}, err
 	}
-	resp, err := c.GetUserByUserId(context.Background(), &userRequest)
+
+	user, err := s.users.GetUserById(ctx, ticket.UserId.String())
+	userUnknown, err := degradeUserLookup(ctx, err)
 	if err != nil {
-		log.Printf("Error: %v", err)
 		return model.DetailTicketResponse{}, err
 	}
+	if userUnknown {
+		user = &grpcserver.UserProto{}
+	}
 
-	historyTickets, err := s.ticketRepository.GetHistoryTicketByTicketId(ticketId)
+	historyTickets, err := s.ticketRepository.GetHistoryTicketByTicketId(ctx, ticketId)
 	if err != nil {
 		return model.DetailTicketResponse{}, err
 	}
 
-	historyTicketResponses := make([]model.HistoryTicketResponse, 0)
-	for _, ht := range historyTickets {
-		htr := model.HistoryTicketResponse{
-			Date:  ht.Date,
-			Title: ht.Title,
-			User:  ht.User,
-		}
-		historyTicketResponses = append(historyTicketResponses, htr)
+	historyTicketResponses, err := s.historyTicketResponses(ctx, historyTickets)
+	if err != nil {
+		return model.DetailTicketResponse{}, err
 	}
 
 	dtr := model.DetailTicketResponse{
 		Id:                    ticket.Id.String(),
-		Username:              resp.User.Name,
-		ProfilePic:            resp.User.ProfilePic,
+		Username:              user.Name,
+		ProfilePic:            user.ProfilePic,
 		Title:                 ticket.Title,
 		Description:           ticket.Description,
 		Status:                ticket.Status,
 		Point:                 ticket.Point,
+		Version:               ticket.Version,
+		UserUnknown:           userUnknown,
 		HistoryTicketResponse: historyTicketResponses,
 	}
 
 	return dtr, nil
 }
 
-func (s *ticketService) UpdateUserTicket(emailAssignee, ticketId, email string) error {
-	resp, err := helper.GetUserByEmailGrpc(s.conn, emailAssignee)
+func (s *ticketService) UpdateUserTicket(ctx context.Context, emailAssignee, ticketId string, match model.VersionMatch, principal model.Principal) (int, error) {
+	assignee, err := lookupAssignee(ctx, s.users, emailAssignee)
+	if err != nil {
+		return 0, err
+	}
+
+	actor, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	ticket, err := s.authorize(ctx, policy.ActionAssign, ticketId, actor.Id, principal)
+	if err != nil {
+		return 0, err
+	}
+	if !match.Matches(ticket.Version) {
+		return 0, repository.ErrVersionConflict
+	}
+
+	historyTicket, err := newHistoryTicket(ticket.Id, model.HistoryEventAssigneeChanged, actor,
+		assigneeValue{UserId: ticket.UserId.String()},
+		assigneeValue{UserId: assignee.Id, Name: assignee.Name})
+	if err != nil {
+		return 0, err
+	}
+
+	event, err := newTicketEvent(model.EventTicketAssigneeChanged, historyTicket)
+	if err != nil {
+		return 0, err
+	}
+
+	newVersion, err := s.ticketRepository.UpdateUserTicket(ctx, assignee.Id, ticketId, ticket.Version, historyTicket, event)
+	if err != nil {
+		return 0, err
+	}
+	s.broker.Publish(event, assignee.Id, ticket.Status)
+
+	return newVersion, nil
+}
+
+func (s *ticketService) UpdateEditTicket(ctx context.Context, ticketId string, match model.VersionMatch, principal model.Principal, editTicket model.EditTicketRequest) (int, error) {
+	actor, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	ticket, err := s.authorize(ctx, policy.ActionEdit, ticketId, actor.Id, principal)
+	if err != nil {
+		return 0, err
+	}
+	if !match.Matches(ticket.Version) {
+		return 0, repository.ErrVersionConflict
+	}
+
+	oldValue, newValue := diffEdit(ticket, editTicket)
+	historyTicket, err := newHistoryTicket(ticket.Id, model.HistoryEventEdited, actor, oldValue, newValue)
+	if err != nil {
+		return 0, err
+	}
+
+	event, err := newTicketEvent(model.EventTicketEdited, historyTicket)
+	if err != nil {
+		return 0, err
+	}
+
+	newVersion, err := s.ticketRepository.UpdateEditTicket(ctx, editTicket, ticketId, ticket.Version, historyTicket, event)
+	if err != nil {
+		return 0, err
+	}
+	s.broker.Publish(event, ticket.UserId.String(), ticket.Status)
+
+	return newVersion, nil
+}
+
+func (s *ticketService) UpdateStatusTicket(ctx context.Context, ticketId string, match model.VersionMatch, principal model.Principal, status string) (int, error) {
+	actor, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	ticket, err := s.authorize(ctx, policy.ActionUpdateStatus, ticketId, actor.Id, principal)
+	if err != nil {
+		return 0, err
+	}
+	if !match.Matches(ticket.Version) {
+		return 0, repository.ErrVersionConflict
+	}
+
+	if err := s.workflow.CheckTransition(ticket.Status, status); err != nil {
+		return 0, apperror.Unprocessable(err.Error(), err)
+	}
+
+	historyTicket, err := newHistoryTicket(ticket.Id, model.HistoryEventStatusChanged, actor,
+		statusValue{Status: ticket.Status}, statusValue{Status: status})
+	if err != nil {
+		return 0, err
+	}
+
+	event, err := newTicketEvent(model.EventTicketStatusChanged, historyTicket)
+	if err != nil {
+		return 0, err
+	}
+
+	newVersion, err := s.ticketRepository.UpdateStatusTicket(ctx, status, ticketId, ticket.Version, historyTicket, event)
+	if err != nil {
+		return 0, err
+	}
+	s.broker.Publish(event, ticket.UserId.String(), status)
+
+	return newVersion, nil
+}
+
+func (s *ticketService) DeleteTicket(ctx context.Context, ticketId string, match model.VersionMatch, principal model.Principal) error {
+	actor, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return err
+	}
+
+	ticket, err := s.authorize(ctx, policy.ActionDelete, ticketId, actor.Id, principal)
 	if err != nil {
 		return err
 	}
+	if !match.Matches(ticket.Version) {
+		return repository.ErrVersionConflict
+	}
 
-	resp2, err := helper.GetUserByEmailGrpc(s.conn, email)
+	historyTicket, err := newHistoryTicket(ticket.Id, model.HistoryEventDeleted, actor, nil, nil)
 	if err != nil {
 		return err
 	}
 
-	historyTicket := model.HistoryTicket{
-		Id:        uuid.New(),
-		TicketId:  uuid.MustParse(ticketId),
-		Date:      time.Now().Format("02 Jan 2006"),
-		Title:     fmt.Sprintf("Change Assignees to %s", resp.User.Name),
-		User:      resp2.User.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	event, err := newTicketEvent(model.EventTicketDeleted, historyTicket)
+	if err != nil {
+		return err
 	}
 
-	if err := s.ticketRepository.UpdateUserTicket(resp.User.Id, ticketId, historyTicket); err != nil {
+	if err := s.ticketRepository.DeleteTicket(ctx, ticketId, ticket.Version, historyTicket, event); err != nil {
 		return err
 	}
+	s.broker.Publish(event, ticket.UserId.String(), ticket.Status)
 
 	return nil
 }
 
-func (s *ticketService) UpdateEditTicket(ticketId, email string, editTicket model.EditTicketRequest) error {
-	resp, err := helper.GetUserByEmailGrpc(s.conn, email)
+func (s *ticketService) RestoreTicket(ctx context.Context, ticketId string, principal model.Principal) error {
+	if err := validateTicketId(ticketId); err != nil {
+		return err
+	}
+
+	actor, err := lookupCaller(ctx, s.users, principal.Email)
 	if err != nil {
 		return err
 	}
 
-	historyTicket := model.HistoryTicket{
-		Id:        uuid.New(),
-		TicketId:  uuid.MustParse(ticketId),
-		Date:      time.Now().Format("02 Jan 2006"),
-		Title:     fmt.Sprintf("Edited by %s", resp.User.Name),
-		User:      resp.User.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	ticket, err := s.ticketRepository.GetDeletedTicketById(ctx, ticketId)
+	if err != nil {
+		return err
 	}
 
-	if err := s.ticketRepository.UpdateEditTicket(editTicket, ticketId, historyTicket); err != nil {
+	if err := s.policy.Authorize(policy.ActionRestore, actor.Id, principal, ticket); err != nil {
+		return policyError(err)
+	}
+
+	historyTicket, err := newHistoryTicket(ticket.Id, model.HistoryEventRestored, actor, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	event, err := newTicketEvent(model.EventTicketRestored, historyTicket)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ticketRepository.RestoreTicket(ctx, ticketId, historyTicket, event); err != nil {
 		return err
 	}
+	s.broker.Publish(event, ticket.UserId.String(), ticket.Status)
 
 	return nil
 }
 
-func (s *ticketService) UpdateStatusTicket(ticketId, email, status string) error {
-	resp, err := helper.GetUserByEmailGrpc(s.conn, email)
+// PurgeDeletedTickets permanently removes tickets that were soft deleted
+// longer ago than the retention period.
+func (s *ticketService) PurgeDeletedTickets(ctx context.Context, principal model.Principal) (int64, error) {
+	if !principal.HasRole(string(policy.RoleAdmin)) {
+		return 0, apperror.Forbidden("purging tickets requires the admin role", nil)
+	}
+
+	return s.ticketRepository.PurgeDeletedTickets(ctx, time.Now().Add(-s.retention))
+}
+
+func (s *ticketService) authorize(ctx context.Context, action policy.Action, ticketId, actorId string, principal model.Principal) (model.Ticket, error) {
+	if err := validateTicketId(ticketId); err != nil {
+		return model.Ticket{}, err
+	}
+
+	ticket, err := s.ticketRepository.GetTicketById(ctx, ticketId)
 	if err != nil {
-		return err
+		return model.Ticket{}, err
 	}
 
-	historyTicket := model.HistoryTicket{
-		Id:        uuid.New(),
-		TicketId:  uuid.MustParse(ticketId),
-		Date:      time.Now().Format("02 Jan 2006"),
-		Title:     fmt.Sprintf("%s Change status to %s", resp.User.Name, status),
-		User:      resp.User.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	if err := s.policy.Authorize(action, actorId, principal, ticket); err != nil {
+		return model.Ticket{}, policyError(err)
 	}
 
-	if err := s.ticketRepository.UpdateStatusTicket(status, ticketId, historyTicket); err != nil {
-		return err
+	return ticket, nil
+}
+
+func policyError(err error) error {
+	var denied *policy.DeniedError
+	if errors.As(err, &denied) {
+		return apperror.Forbidden(denied.Reason, err)
+	}
+	return err
+}
+
+// lookupCaller resolves the authenticated caller. A valid token for an email
+// the user service doesn't know, e.g. of a removed account, is rejected like
+// any other bad credential rather than reported as a missing resource.
+func lookupCaller(ctx context.Context, users directory.UserDirectory, email string) (*grpcserver.UserProto, error) {
+	user, err := users.GetUserByEmail(ctx, email)
+	if errors.Is(err, directory.ErrUserNotFound) {
+		return nil, apperror.Unauthenticated("no user account for this token", err)
+	}
+	return user, err
+}
+
+// lookupAssignee resolves an assignee named in the request body, so an
+// unknown one makes the request unprocessable.
+func lookupAssignee(ctx context.Context, users directory.UserDirectory, email string) (*grpcserver.UserProto, error) {
+	user, err := users.GetUserByEmail(ctx, email)
+	if errors.Is(err, directory.ErrUserNotFound) {
+		return nil, apperror.Unprocessable("assignee not found", err)
 	}
+	return user, err
+}
 
+func validateTicketId(ticketId string) error {
+	if _, err := uuid.Parse(ticketId); err != nil {
+		return apperror.InvalidArgument("invalid ticket id", err)
+	}
 	return nil
 }
 
-func (s *ticketService) Summary(email string) ([]model.SummaryResponse, error) {
-	resp, err := helper.GetUserByEmailGrpc(s.conn, email)
+func (s *ticketService) Summary(ctx context.Context, email string) ([]model.SummaryResponse, error) {
+	user, err := lookupCaller(ctx, s.users, email)
 	if err != nil {
 		return nil, err
 	}
 
 	var summaryResponses []model.SummaryResponse
-	result, err := s.ticketRepository.CountTicketGroupByStatus(resp.User.Id)
+	result, err := s.ticketRepository.CountTicketGroupByStatus(ctx, user.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -248,7 +458,7 @@ func (s *ticketService) Summary(email string) ([]model.SummaryResponse, error) {
 
 	}
 
-	result2, err := s.ticketRepository.SumTicketGroupByStatus(resp.User.Id)
+	result2, err := s.ticketRepository.SumTicketGroupByStatus(ctx, user.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -263,28 +473,28 @@ func (s *ticketService) Summary(email string) ([]model.SummaryResponse, error) {
 	return summaryResponses, nil
 }
 
-func (s *ticketService) Performance(email string) (model.Performance, error) {
-	resp, err := helper.GetUserByEmailGrpc(s.conn, email)
+func (s *ticketService) Performance(ctx context.Context, email string) (model.Performance, error) {
+	user, err := lookupCaller(ctx, s.users, email)
 	if err != nil {
 		return model.Performance{}, err
 	}
 
-	completedTask, err := s.ticketRepository.CountTicketDone(resp.User.Id)
+	completedTask, err := s.ticketRepository.CountTicketCompleted(ctx, user.Id, s.workflow.Completed)
 	if err != nil {
 		return model.Performance{}, err
 	}
 
-	totalTask, err := s.ticketRepository.CountTicket(resp.User.Id)
+	totalTask, err := s.ticketRepository.CountTicket(ctx, user.Id)
 	if err != nil {
 		return model.Performance{}, err
 	}
 
-	completedPoint, err := s.ticketRepository.SumPointTicketDone(resp.User.Id)
+	completedPoint, err := s.ticketRepository.SumPointTicketCompleted(ctx, user.Id, s.workflow.Completed)
 	if err != nil {
 		return model.Performance{}, err
 	}
 
-	totalPoint, err := s.ticketRepository.SumPointTicket(resp.User.Id)
+	totalPoint, err := s.ticketRepository.SumPointTicket(ctx, user.Id)
 	if err != nil {
 		return model.Performance{}, err
 	}
@@ -302,3 +512,15 @@ func (s *ticketService) Performance(email string) (model.Performance, error) {
 
 	return performance, nil
 }
+
+// degradeUserLookup lets reads carry on when the user service is down
+// or its circuit breaker is open: it reports the users as unknown instead of
+// failing the request. Any other error is returned unchanged.
+func degradeUserLookup(ctx context.Context, err error) (bool, error) {
+	if err == nil || apperror.KindOf(err) != apperror.KindUpstream {
+		return false, err
+	}
+
+	slog.WarnContext(ctx, "User service unavailable, returning unknown users", "error", err)
+	return true, nil
+}
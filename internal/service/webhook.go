@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/directory"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/policy"
+	"github.com/gemm123/vkrf-ticket/internal/repository"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+type webhookService struct {
+	webhookRepository repository.WebhookRepository
+	users             directory.UserDirectory
+}
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, principal model.Principal, webhook model.WebhookRequest) (model.CreatedWebhookResponse, error)
+	GetWebhooks(ctx context.Context, principal model.Principal) ([]model.Webhook, error)
+	GetWebhook(ctx context.Context, webhookId string, principal model.Principal) (model.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhookId string, principal model.Principal, webhook model.WebhookRequest) (model.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookId string, principal model.Principal) error
+	GetDeliveries(ctx context.Context, webhookId string, limit int, principal model.Principal) ([]model.WebhookDelivery, error)
+	Redeliver(ctx context.Context, webhookId, deliveryId string, principal model.Principal) (model.WebhookDelivery, error)
+}
+
+func NewWebhookService(webhookRepository repository.WebhookRepository, users directory.UserDirectory) WebhookService {
+	return &webhookService{webhookRepository: webhookRepository, users: users}
+}
+
+func (s *webhookService) CreateWebhook(ctx context.Context, principal model.Principal, webhook model.WebhookRequest) (model.CreatedWebhookResponse, error) {
+	if err := requireWebhookAdmin(principal); err != nil {
+		return model.CreatedWebhookResponse{}, err
+	}
+	if err := validateEventTypes(webhook.EventTypes); err != nil {
+		return model.CreatedWebhookResponse{}, err
+	}
+
+	creator, err := lookupCaller(ctx, s.users, principal.Email)
+	if err != nil {
+		return model.CreatedWebhookResponse{}, err
+	}
+	creatorId, err := uuid.Parse(creator.Id)
+	if err != nil {
+		return model.CreatedWebhookResponse{}, apperror.Upstream("user service returned an invalid user id", err)
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return model.CreatedWebhookResponse{}, err
+	}
+
+	now := time.Now()
+	w := model.Webhook{
+		Id:         uuid.New(),
+		URL:        webhook.URL,
+		Secret:     secret,
+		EventTypes: eventTypesOrEmpty(webhook.EventTypes),
+		Active:     webhook.Active == nil || *webhook.Active,
+		CreatedBy:  creatorId,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.webhookRepository.CreateWebhook(ctx, w); err != nil {
+		return model.CreatedWebhookResponse{}, err
+	}
+
+	return model.CreatedWebhookResponse{Webhook: w, Secret: secret}, nil
+}
+
+func (s *webhookService) GetWebhooks(ctx context.Context, principal model.Principal) ([]model.Webhook, error) {
+	if err := requireWebhookAdmin(principal); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.webhookRepository.GetWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = make([]model.Webhook, 0)
+	}
+
+	return webhooks, nil
+}
+
+func (s *webhookService) GetWebhook(ctx context.Context, webhookId string, principal model.Principal) (model.Webhook, error) {
+	if err := requireWebhookAdmin(principal); err != nil {
+		return model.Webhook{}, err
+	}
+	if err := validateWebhookId(webhookId); err != nil {
+		return model.Webhook{}, err
+	}
+
+	return s.webhookRepository.GetWebhookById(ctx, webhookId)
+}
+
+func (s *webhookService) UpdateWebhook(ctx context.Context, webhookId string, principal model.Principal, webhook model.WebhookRequest) (model.Webhook, error) {
+	w, err := s.GetWebhook(ctx, webhookId, principal)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	if err := validateEventTypes(webhook.EventTypes); err != nil {
+		return model.Webhook{}, err
+	}
+
+	w.URL = webhook.URL
+	w.EventTypes = eventTypesOrEmpty(webhook.EventTypes)
+	if webhook.Active != nil {
+		w.Active = *webhook.Active
+	}
+	w.UpdatedAt = time.Now()
+
+	if err := s.webhookRepository.UpdateWebhook(ctx, w); err != nil {
+		return model.Webhook{}, err
+	}
+
+	return w, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, webhookId string, principal model.Principal) error {
+	if err := requireWebhookAdmin(principal); err != nil {
+		return err
+	}
+	if err := validateWebhookId(webhookId); err != nil {
+		return err
+	}
+
+	return s.webhookRepository.DeleteWebhook(ctx, webhookId)
+}
+
+func (s *webhookService) GetDeliveries(ctx context.Context, webhookId string, limit int, principal model.Principal) ([]model.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookId, principal); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhookRepository.GetDeliveriesByWebhookId(ctx, webhookId, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = make([]model.WebhookDelivery, 0)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a fresh delivery of the same payload. The original entry
+// is kept so the delivery log shows every attempt.
+func (s *webhookService) Redeliver(ctx context.Context, webhookId, deliveryId string, principal model.Principal) (model.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookId, principal); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	if _, err := uuid.Parse(deliveryId); err != nil {
+		return model.WebhookDelivery{}, apperror.InvalidArgument("invalid delivery id", err)
+	}
+
+	original, err := s.webhookRepository.GetDeliveryById(ctx, webhookId, deliveryId)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	now := time.Now()
+	delivery := model.WebhookDelivery{
+		Id:            uuid.New(),
+		WebhookId:     original.WebhookId,
+		EventId:       original.EventId,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        model.DeliveryPending,
+		RedeliveryOf:  &original.Id,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	if err := s.webhookRepository.CreateDelivery(ctx, delivery); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	return delivery, nil
+}
+
+func requireWebhookAdmin(principal model.Principal) error {
+	if !principal.HasRole(string(policy.RoleAdmin)) {
+		return apperror.Forbidden("managing webhooks requires the admin role", nil)
+	}
+	return nil
+}
+
+func validateWebhookId(webhookId string) error {
+	if _, err := uuid.Parse(webhookId); err != nil {
+		return apperror.InvalidArgument("invalid webhook id", err)
+	}
+	return nil
+}
+
+func validateEventTypes(eventTypes []string) error {
+	for _, eventType := range eventTypes {
+		if !slices.Contains(model.EventTypes, eventType) {
+			return apperror.InvalidArgument(fmt.Sprintf("unknown event type %q", eventType), nil)
+		}
+	}
+	return nil
+}
+
+func eventTypesOrEmpty(eventTypes []string) []string {
+	if eventTypes == nil {
+		return []string{}
+	}
+	return eventTypes
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
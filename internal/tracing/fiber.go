@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Middleware starts a server span for each request, continuing the trace from
+// the incoming traceparent header, and stores it in the user context so the
+// repository and user service spans nest under it. It is registered ahead of
+// the metrics middleware, which renders handler errors, so the recorded
+// status is the one sent.
+func Middleware() fiber.Handler {
+	tracer := otel.Tracer(instrumentationName)
+
+	return func(ctx *fiber.Ctx) error {
+		header := make(http.Header)
+		ctx.Request().Header.VisitAll(func(key, value []byte) {
+			header.Add(string(key), string(value))
+		})
+		reqCtx := otel.GetTextMapPropagator().Extract(ctx.UserContext(), propagation.HeaderCarrier(header))
+
+		reqCtx, span := tracer.Start(reqCtx, ctx.Method(), trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Method()),
+				semconv.URLPath(ctx.Path()),
+				semconv.ClientAddress(ctx.IP()),
+				semconv.UserAgentOriginal(string(ctx.Request().Header.UserAgent())),
+			))
+		defer span.End()
+
+		ctx.SetUserContext(reqCtx)
+		err := ctx.Next()
+
+		// The route is only known once the router has matched it.
+		route := ctx.Route().Path
+		span.SetName(ctx.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		status := ctx.Response().StatusCode()
+		if err != nil {
+			span.RecordError(err)
+			status = fiber.StatusInternalServerError
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+type queryTracer struct {
+	tracer   trace.Tracer
+	database string
+}
+
+// NewQueryTracer returns a pgx tracer that opens a client span for every
+// Query, QueryRow and Exec. Spans are named after the statement, e.g.
+// "SELECT tickets", and carry the SQL text but never its arguments.
+func NewQueryTracer(database string) pgx.QueryTracer {
+	return &queryTracer{
+		tracer:   otel.Tracer(instrumentationName),
+		database: database,
+	}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, table := statementName(data.SQL)
+
+	name := operation
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNamespace(t.database),
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(data.SQL),
+	}
+	if table != "" {
+		name += " " + table
+		attrs = append(attrs, semconv.DBCollectionName(table))
+	}
+
+	ctx, _ = t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	} else {
+		span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	span.End()
+}
+
+// statementName returns the SQL operation and the table it works on, taken
+// from the first FROM, INTO or UPDATE in the statement. Statements without
+// one, like BEGIN, only get the operation.
+func statementName(sql string) (string, string) {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	operation := strings.ToUpper(fields[0])
+	for i, field := range fields[:len(fields)-1] {
+		switch strings.ToUpper(field) {
+		case "FROM", "INTO", "UPDATE":
+			table := strings.Trim(fields[i+1], `"(`)
+			if table != "" && !strings.HasPrefix(table, "$") {
+				return operation, table
+			}
+		}
+	}
+
+	return operation, ""
+}
@@ -0,0 +1,126 @@
+package userclient
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker opens after threshold consecutive failures and rejects calls for
+// cooldown. It then lets a single probe through: success closes it again,
+// failure starts another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may go ahead and whether it is the half-open
+// probe. Only the probe's outcome can close or reopen a half-open breaker.
+func (b *breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		b.setState(stateHalfOpen)
+		b.probing = true
+		return true, true
+	case stateHalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// success and failure record a call's outcome. Outside the probe, an outcome
+// that arrives while the breaker isn't closed comes from a call started
+// before it opened and says nothing about the service now, so it is ignored.
+func (b *breaker) success(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case probe:
+		b.probing = false
+		b.failures = 0
+		b.setState(stateClosed)
+	case b.state == stateClosed:
+		b.failures = 0
+	}
+}
+
+func (b *breaker) failure(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case probe:
+		b.probing = false
+		b.openedAt = time.Now()
+		b.setState(stateOpen)
+	case b.state == stateClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = time.Now()
+			b.setState(stateOpen)
+		}
+	}
+}
+
+// release ends a call whose outcome says nothing about the service, freeing
+// the probe slot if it held it.
+func (b *breaker) release(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+}
+
+func (b *breaker) setState(state breakerState) {
+	level := slog.LevelInfo
+	if state == stateOpen {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "User service circuit breaker changed state",
+		"from", b.state.String(), "to", state.String())
+	b.state = state
+}
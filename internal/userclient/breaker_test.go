@@ -0,0 +1,98 @@
+package userclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newBreaker(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		b.failure(false)
+		if ok, _ := b.allow(); !ok {
+			t.Fatalf("allow() = false after %d failures, want true", i+1)
+		}
+	}
+
+	b.failure(false)
+	if b.state != stateOpen {
+		t.Fatalf("state = %s, want open", b.state)
+	}
+	if ok, _ := b.allow(); ok {
+		t.Fatal("allow() = true during cooldown, want false")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newBreaker(2, time.Hour)
+
+	b.failure(false)
+	b.success(false)
+	b.failure(false)
+	if b.state != stateClosed {
+		t.Fatalf("state = %s, want closed", b.state)
+	}
+}
+
+func TestBreakerIgnoresStaleOutcomes(t *testing.T) {
+	b := newBreaker(1, time.Millisecond)
+	b.failure(false)
+
+	// A call that started before the breaker opened finishes now.
+	b.success(false)
+	if b.state != stateOpen {
+		t.Fatalf("state = %s after a stale success, want open", b.state)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if ok, probe := b.allow(); !ok || !probe {
+		t.Fatalf("allow() = %v, %v after cooldown, want the probe", ok, probe)
+	}
+	b.success(false)
+	b.failure(false)
+	if b.state != stateHalfOpen || !b.probing {
+		t.Fatalf("state = %s, probing = %v, want half_open waiting for the probe", b.state, b.probing)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(b *breaker, probe bool)
+		state  breakerState
+		allow  bool
+	}{
+		{"probe succeeds", (*breaker).success, stateClosed, true},
+		{"probe fails", (*breaker).failure, stateOpen, false},
+		{"probe released", (*breaker).release, stateHalfOpen, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(1, time.Millisecond)
+			b.failure(false)
+			time.Sleep(2 * time.Millisecond)
+
+			ok, probe := b.allow()
+			if !ok || !probe {
+				t.Fatalf("allow() = %v, %v after cooldown, want the probe", ok, probe)
+			}
+			if b.state != stateHalfOpen {
+				t.Fatalf("state = %s, want half_open", b.state)
+			}
+			if ok, _ := b.allow(); ok {
+				t.Fatal("allow() = true while probing, want false")
+			}
+
+			b.cooldown = time.Hour
+			tt.finish(b, probe)
+			if b.state != tt.state {
+				t.Fatalf("state = %s, want %s", b.state, tt.state)
+			}
+			if ok, _ := b.allow(); ok != tt.allow {
+				t.Errorf("allow() = %v, want %v", ok, tt.allow)
+			}
+		})
+	}
+}
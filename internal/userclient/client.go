@@ -0,0 +1,138 @@
+package userclient
+
+import (
+	"context"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the user service while the
+// circuit breaker is open. It is an Unavailable status like a failed call.
+var ErrCircuitOpen = status.Error(codes.Unavailable, "user service circuit breaker is open")
+
+type Options struct {
+	CallTimeout      time.Duration
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+type client struct {
+	next    grpcserver.UserServiceClient
+	opts    Options
+	breaker *breaker
+}
+
+// New wraps next so each attempt gets its own deadline, Unavailable errors
+// are retried with jittered exponential backoff and repeated failures trip a
+// circuit breaker shared by all methods.
+func New(next grpcserver.UserServiceClient, opts Options) grpcserver.UserServiceClient {
+	return &client{
+		next:    next,
+		opts:    opts,
+		breaker: newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
+}
+
+func (c *client) GetUserByEmail(ctx context.Context, in *grpcserver.GetUserByEmailRequest, opts ...grpc.CallOption) (*grpcserver.GetUserByEmailResponse, error) {
+	var resp *grpcserver.GetUserByEmailResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.next.GetUserByEmail(ctx, in, opts...)
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *client) GetUserByUserId(ctx context.Context, in *grpcserver.GetUserByUserIdRequest, opts ...grpc.CallOption) (*grpcserver.GetUserByUserIdResponse, error) {
+	var resp *grpcserver.GetUserByUserIdResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.next.GetUserByUserId(ctx, in, opts...)
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *client) GetUsersByIds(ctx context.Context, in *grpcserver.GetUsersByIdsRequest, opts ...grpc.CallOption) (*grpcserver.GetUsersByIdsResponse, error) {
+	var resp *grpcserver.GetUsersByIdsResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.next.GetUsersByIds(ctx, in, opts...)
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *client) call(ctx context.Context, invoke func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		ok, probe := c.breaker.allow()
+		if !ok {
+			// Report the failure that tripped the breaker on a retry.
+			if err != nil {
+				return err
+			}
+			return ErrCircuitOpen
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, c.opts.CallTimeout)
+		err = invoke(callCtx)
+		cancel()
+
+		// The caller giving up says nothing about the user service's health.
+		if ctx.Err() != nil {
+			if err != nil {
+				c.breaker.release(probe)
+			} else {
+				c.breaker.success(probe)
+			}
+			return err
+		}
+		if isServiceFailure(err) {
+			c.breaker.failure(probe)
+		} else {
+			c.breaker.success(probe)
+		}
+
+		if status.Code(err) != codes.Unavailable || attempt >= c.opts.MaxRetries {
+			return err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff picks a random delay up to RetryBackoff doubled per attempt, capped
+// at RetryMaxBackoff ("full jitter"), so callers retrying together spread out.
+func (c *client) backoff(attempt int) time.Duration {
+	ceiling := c.opts.RetryBackoff << attempt
+	if ceiling <= 0 || ceiling > c.opts.RetryMaxBackoff {
+		ceiling = c.opts.RetryMaxBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// isServiceFailure reports whether err means the user service is unhealthy,
+// as opposed to answering with an application error such as NotFound.
+func isServiceFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,162 @@
+package userclient
+
+import (
+	"context"
+	"errors"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// stubUserService answers GetUserByEmail with errs in turn, then with nil.
+type stubUserService struct {
+	grpcserver.UserServiceClient
+	errs  []error
+	calls int
+}
+
+func (s *stubUserService) GetUserByEmail(ctx context.Context, in *grpcserver.GetUserByEmailRequest, opts ...grpc.CallOption) (*grpcserver.GetUserByEmailResponse, error) {
+	s.calls++
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, errors.New("attempt has no deadline")
+	}
+	if len(s.errs) == 0 {
+		return &grpcserver.GetUserByEmailResponse{}, nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return nil, err
+}
+
+func testOptions() Options {
+	return Options{
+		CallTimeout:      time.Second,
+		MaxRetries:       2,
+		RetryBackoff:     time.Millisecond,
+		RetryMaxBackoff:  2 * time.Millisecond,
+		BreakerThreshold: 10,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+func TestCallRetries(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	notFound := status.Error(codes.NotFound, "not found")
+	deadline := status.Error(codes.DeadlineExceeded, "deadline exceeded")
+
+	tests := []struct {
+		name  string
+		errs  []error
+		want  codes.Code
+		calls int
+	}{
+		{"success", nil, codes.OK, 1},
+		{"recovers after unavailable", []error{unavailable, unavailable}, codes.OK, 3},
+		{"gives up after max retries", []error{unavailable, unavailable, unavailable}, codes.Unavailable, 3},
+		{"application error isn't retried", []error{notFound}, codes.NotFound, 1},
+		{"deadline exceeded isn't retried", []error{deadline}, codes.DeadlineExceeded, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubUserService{errs: tt.errs}
+			c := New(stub, testOptions())
+
+			_, err := c.GetUserByEmail(context.Background(), &grpcserver.GetUserByEmailRequest{})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %s, want %s (err %v)", got, tt.want, err)
+			}
+			if stub.calls != tt.calls {
+				t.Errorf("calls = %d, want %d", stub.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCallTripsBreaker(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	opts := testOptions()
+	opts.MaxRetries = 5
+	opts.BreakerThreshold = 2
+	stub := &stubUserService{errs: []error{unavailable, unavailable, unavailable}}
+	c := New(stub, opts)
+
+	_, err := c.GetUserByEmail(context.Background(), &grpcserver.GetUserByEmailRequest{})
+	if !errors.Is(err, unavailable) {
+		t.Fatalf("err = %v, want the failure that tripped the breaker", err)
+	}
+	if stub.calls != 2 {
+		t.Fatalf("calls = %d, want retries to stop once the breaker opens", stub.calls)
+	}
+
+	_, err = c.GetUserByEmail(context.Background(), &grpcserver.GetUserByEmailRequest{})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("calls = %d, want no call while the breaker is open", stub.calls)
+	}
+}
+
+func TestCallApplicationErrorsKeepBreakerClosed(t *testing.T) {
+	notFound := status.Error(codes.NotFound, "not found")
+	opts := testOptions()
+	opts.BreakerThreshold = 1
+	stub := &stubUserService{errs: []error{notFound, notFound}}
+	c := New(stub, opts)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetUserByEmail(context.Background(), &grpcserver.GetUserByEmailRequest{}); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: breaker opened on application errors", i+1)
+		}
+	}
+	if stub.calls != 3 {
+		t.Errorf("calls = %d, want 3", stub.calls)
+	}
+}
+
+func TestCallStopsRetryingWhenContextIsDone(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	opts := testOptions()
+	opts.RetryBackoff = time.Hour
+	opts.RetryMaxBackoff = time.Hour
+	stub := &stubUserService{errs: []error{unavailable, unavailable}}
+	c := New(stub, opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetUserByEmail(ctx, &grpcserver.GetUserByEmailRequest{})
+	if !errors.Is(err, unavailable) {
+		t.Fatalf("err = %v, want the last attempt's error", err)
+	}
+	if stub.calls > 2 {
+		t.Errorf("calls = %d, want the backoff wait to end with the context", stub.calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &client{opts: Options{RetryBackoff: 10 * time.Millisecond, RetryMaxBackoff: 50 * time.Millisecond}}
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{1, 20 * time.Millisecond},
+		{2, 40 * time.Millisecond},
+		{3, 50 * time.Millisecond},
+		{70, 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := c.backoff(tt.attempt); d < 0 || d > tt.ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [0, %s]", tt.attempt, d, tt.ceiling)
+			}
+		}
+	}
+}
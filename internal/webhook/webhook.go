@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/repository"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	signaturePrefix = "sha256="
+
+	recordTimeout = 10 * time.Second
+)
+
+// Sign returns the signature header value for body: the hex encoded
+// HMAC-SHA256 of the timestamp, a dot and the raw body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher is an outbox publisher that turns each event into one pending
+// delivery per subscribed webhook.
+type Dispatcher struct {
+	webhookRepository repository.WebhookRepository
+}
+
+func NewDispatcher(webhookRepository repository.WebhookRepository) *Dispatcher {
+	return &Dispatcher{webhookRepository: webhookRepository}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event model.Event) error {
+	return d.webhookRepository.EnqueueDeliveries(ctx, event)
+}
+
+type Worker struct {
+	webhookRepository repository.WebhookRepository
+	client            *http.Client
+	interval          time.Duration
+	batchSize         int
+	maxAttempts       int
+	lease             time.Duration
+}
+
+func NewWorker(webhookRepository repository.WebhookRepository, interval time.Duration, batchSize, maxAttempts int, lease time.Duration) *Worker {
+	return &Worker{
+		webhookRepository: webhookRepository,
+		client:            &http.Client{Timeout: 10 * time.Second},
+		interval:          interval,
+		batchSize:         batchSize,
+		maxAttempts:       maxAttempts,
+		lease:             lease,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		processed, err := w.deliverBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Webhook delivery failed", "error", err)
+		}
+
+		if processed == w.batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(w.interval)
+		}
+	}
+}
+
+// deliverBatch claims due deliveries, sends them without holding any
+// transaction and records the results, releasing whatever a shutdown
+// interrupted.
+func (w *Worker) deliverBatch(ctx context.Context) (int, error) {
+	pending, err := w.webhookRepository.ClaimPendingDeliveries(ctx, w.batchSize, w.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]model.DeliveryResult, 0, len(pending))
+	for _, p := range pending {
+		if ctx.Err() != nil {
+			results = append(results, model.DeliveryResult{DeliveryId: p.Delivery.Id, Skipped: true})
+			continue
+		}
+		result := w.deliver(ctx, p.Webhook, p.Delivery)
+		result.DeliveryId = p.Delivery.Id
+		result.Skipped = result.Err != nil && ctx.Err() != nil
+		results = append(results, result)
+	}
+
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	return len(pending), w.webhookRepository.CompleteDeliveries(recordCtx, w.maxAttempts, results)
+}
+
+func (w *Worker) deliver(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) model.DeliveryResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return model.DeliveryResult{Err: err}
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", webhook.Id.String())
+	req.Header.Set("X-Webhook-Delivery", delivery.Id.String())
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return model.DeliveryResult{Err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return model.DeliveryResult{
+			ResponseStatus: resp.StatusCode,
+			Err:            fmt.Errorf("webhook responded with status %d", resp.StatusCode),
+		}
+	}
+
+	return model.DeliveryResult{ResponseStatus: resp.StatusCode}
+}
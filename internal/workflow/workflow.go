@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Workflow struct {
+	States      []string            `json:"states"`
+	Initial     []string            `json:"initial"`
+	Transitions map[string][]string `json:"transitions"`
+	Completed   []string            `json:"completed"`
+}
+
+type TransitionError struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed []string `json:"allowed"`
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("status %q is not a valid initial status, allowed: %s", e.To, strings.Join(e.Allowed, ", "))
+	}
+	return fmt.Sprintf("cannot change status from %q to %q, allowed: %s", e.From, e.To, strings.Join(e.Allowed, ", "))
+}
+
+func Default() Workflow {
+	return Workflow{
+		States:  []string{"todo", "in_progress", "review", "done"},
+		Initial: []string{"todo", "in_progress"},
+		Transitions: map[string][]string{
+			"todo":        {"in_progress", "done"},
+			"in_progress": {"todo", "review", "done"},
+			"review":      {"in_progress", "done"},
+			"done":        {"in_progress"},
+		},
+		Completed: []string{"done"},
+	}
+}
+
+// Load reads a workflow definition from a JSON file, or returns Default when
+// path is empty.
+func Load(path string) (Workflow, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	w := Workflow{}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return Workflow{}, fmt.Errorf("invalid workflow file: %w", err)
+	}
+	if err := w.Validate(); err != nil {
+		return Workflow{}, err
+	}
+
+	return w, nil
+}
+
+func (w Workflow) Validate() error {
+	if len(w.States) == 0 {
+		return errors.New("workflow must define at least one state")
+	}
+	if len(w.Initial) == 0 {
+		return errors.New("workflow must define at least one initial state")
+	}
+
+	for _, s := range w.Initial {
+		if !w.HasState(s) {
+			return fmt.Errorf("initial state %q is not a workflow state", s)
+		}
+	}
+	for _, s := range w.Completed {
+		if !w.HasState(s) {
+			return fmt.Errorf("completed state %q is not a workflow state", s)
+		}
+	}
+	for from, targets := range w.Transitions {
+		if !w.HasState(from) {
+			return fmt.Errorf("transition source %q is not a workflow state", from)
+		}
+		for _, to := range targets {
+			if !w.HasState(to) {
+				return fmt.Errorf("transition target %q is not a workflow state", to)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w Workflow) HasState(state string) bool {
+	return contains(w.States, state)
+}
+
+// NextStates lists where a ticket in from may move. A status outside the
+// workflow, such as "to do" from before workflows existed or a state dropped
+// from the configuration, may move to any initial state so the ticket is not
+// stuck.
+func (w Workflow) NextStates(from string) []string {
+	if !w.HasState(from) {
+		return w.Initial
+	}
+
+	next := w.Transitions[from]
+	if next == nil {
+		return []string{}
+	}
+	return next
+}
+
+func (w Workflow) CheckInitial(status string) error {
+	if !contains(w.Initial, status) {
+		return &TransitionError{To: status, Allowed: w.Initial}
+	}
+	return nil
+}
+
+func (w Workflow) CheckTransition(from, to string) error {
+	if !contains(w.NextStates(from), to) {
+		return &TransitionError{From: from, To: to, Allowed: w.NextStates(from)}
+	}
+	return nil
+}
+
+func (w Workflow) IsCompleted(status string) bool {
+	return contains(w.Completed, status)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
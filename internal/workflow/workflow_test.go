@@ -0,0 +1,65 @@
+package workflow
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	w := Default()
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		allowed bool
+	}{
+		{"defined transition", "todo", "in_progress", true},
+		{"undefined transition", "todo", "review", false},
+		{"legacy status to initial state", "to do", "todo", true},
+		{"legacy status to another initial state", "to do", "in_progress", true},
+		{"legacy status to non-initial state", "to do", "done", false},
+		{"legacy status to itself", "to do", "to do", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.CheckTransition(tt.from, tt.to)
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("CheckTransition(%q, %q) = %v, want nil", tt.from, tt.to, err)
+				}
+				return
+			}
+
+			var transitionErr *TransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Fatalf("CheckTransition(%q, %q) = %v, want *TransitionError", tt.from, tt.to, err)
+			}
+			if !reflect.DeepEqual(transitionErr.Allowed, w.NextStates(tt.from)) {
+				t.Errorf("allowed = %v, want %v", transitionErr.Allowed, w.NextStates(tt.from))
+			}
+		})
+	}
+}
+
+func TestNextStatesFromLegacyStatus(t *testing.T) {
+	w := Default()
+
+	if got := w.NextStates("to do"); !reflect.DeepEqual(got, w.Initial) {
+		t.Fatalf("NextStates(%q) = %v, want the initial states %v", "to do", got, w.Initial)
+	}
+}
+
+func TestNextStatesFromTerminalState(t *testing.T) {
+	w := Workflow{
+		States:      []string{"open", "closed"},
+		Initial:     []string{"open"},
+		Transitions: map[string][]string{"open": {"closed"}},
+	}
+
+	if got := w.NextStates("closed"); got == nil || len(got) != 0 {
+		t.Fatalf("NextStates(%q) = %#v, want an empty list", "closed", got)
+	}
+}
@@ -1,60 +1,399 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gemm123/vkrf-ticket/config"
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/broker"
 	"github.com/gemm123/vkrf-ticket/internal/controller"
+	"github.com/gemm123/vkrf-ticket/internal/directory"
+	grpcserver "github.com/gemm123/vkrf-ticket/internal/grpc"
+	"github.com/gemm123/vkrf-ticket/internal/health"
+	"github.com/gemm123/vkrf-ticket/internal/logging"
+	"github.com/gemm123/vkrf-ticket/internal/metrics"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"github.com/gemm123/vkrf-ticket/internal/outbox"
+	"github.com/gemm123/vkrf-ticket/internal/policy"
 	"github.com/gemm123/vkrf-ticket/internal/repository"
 	"github.com/gemm123/vkrf-ticket/internal/service"
+	"github.com/gemm123/vkrf-ticket/internal/tracing"
+	"github.com/gemm123/vkrf-ticket/internal/userclient"
+	"github.com/gemm123/vkrf-ticket/internal/webhook"
+	"github.com/gemm123/vkrf-ticket/internal/workflow"
 	"github.com/gemm123/vkrf-ticket/middleware"
+	"github.com/gemm123/vkrf-ticket/migration"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"log"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
+	logLevel := new(slog.LevelVar)
+	slog.SetDefault(logging.New(os.Stdout, logLevel))
+
 	err := godotenv.Load()
 	if err != nil {
-		log.Printf("Error loading .env file: %v\n", err)
+		slog.Warn("Could not load .env file", "error", err)
+	}
+
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	logLevel.Set(cfg.Log.SlogLevel())
+
+	db, err := config.InitConnPool(cfg.Database, tracing.NewQueryTracer(cfg.Database.Name))
+	if err != nil {
+		fatal("Could not connect to the database", "error", err)
 	}
 
-	db := config.InitConnPool()
-	defer db.Close()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(db, os.Args[2:])
+		db.Close()
+		if err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		return
+	}
+
+	if cfg.Database.AutoMigrate {
+		if err := migration.Up(context.Background(), db); err != nil {
+			fatal("Migration failed", "error", err)
+		}
+	}
 
-	var conn *grpc.ClientConn
-	conn, err = grpc.Dial(":9000", grpc.WithInsecure())
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName:  cfg.Tracing.ServiceName,
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Could not set up tracing", "error", err)
+	}
+
+	registry := metrics.NewRegistry()
+	registry.MustRegister(metrics.NewPoolCollector(db))
+	grpcClientMetrics := metrics.NewGrpcClientMetrics(registry)
+
+	conn, err := config.DialUserService(cfg.UserService,
+		grpc.WithChainUnaryInterceptor(grpcClientMetrics.UnaryClientInterceptor(), logging.UnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	if err != nil {
+		fatal("Could not connect to the user service", "error", err)
+	}
+
+	jwtVerifier, err := helper.NewJWTVerifier(helper.JWTConfig{
+		Algorithm:     cfg.JWT.Algorithm,
+		Secret:        cfg.JWT.Secret,
+		PublicKeyFile: cfg.JWT.PublicKeyFile,
+		JWKSFile:      cfg.JWT.JWKSFile,
+		Issuer:        cfg.JWT.Issuer,
+		Audience:      cfg.JWT.Audience,
+	})
 	if err != nil {
-		log.Fatalf("Could not connect: %v", err)
+		fatal("Could not create JWT verifier", "error", err)
+	}
+
+	policyRules, err := policy.ParseRules(cfg.Ticket.Policy)
+	if err != nil {
+		fatal("Invalid ticket policy", "error", err)
+	}
+	ticketPolicy := policy.NewPolicy(policyRules)
+
+	ticketWorkflow, err := workflow.Load(cfg.Ticket.WorkflowFile)
+	if err != nil {
+		fatal("Invalid workflow", "error", err)
 	}
-	defer conn.Close()
 
 	validate := validator.New()
 
+	userClient := userclient.New(grpcserver.NewUserServiceClient(conn), userclient.Options{
+		CallTimeout:      cfg.UserService.CallTimeout,
+		MaxRetries:       cfg.UserService.MaxRetries,
+		RetryBackoff:     cfg.UserService.RetryBackoff,
+		RetryMaxBackoff:  cfg.UserService.RetryMaxBackoff,
+		BreakerThreshold: cfg.UserService.BreakerThreshold,
+		BreakerCooldown:  cfg.UserService.BreakerCooldown,
+	})
+	userDirectory := directory.NewCachedUserDirectory(directory.NewGrpcUserDirectory(userClient), directory.CacheOptions{
+		Size:        cfg.UserCache.Size,
+		TTL:         cfg.UserCache.TTL,
+		NegativeTTL: cfg.UserCache.NegativeTTL,
+	})
+
+	metrics.RegisterUserCache(registry, userDirectory)
+
 	ticketRepository := repository.NewTicketRepository(db)
+	commentRepository := repository.NewCommentRepository(db)
+	outboxRepository := repository.NewOutboxRepository(db)
+	webhookRepository := repository.NewWebhookRepository(db)
+
+	registry.MustRegister(metrics.NewTicketCollector(ticketRepository, ticketWorkflow.States))
+
+	eventPublisher, publisherWorkers := newEventPublisher(cfg.Outbox, webhook.NewDispatcher(webhookRepository))
+	relay := outbox.NewRelay(outboxRepository, eventPublisher, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize,
+		cfg.Outbox.MaxAttempts, cfg.Outbox.LeaseDuration)
+	webhookWorker := webhook.NewWorker(webhookRepository, cfg.Webhook.PollInterval, cfg.Webhook.BatchSize,
+		cfg.Webhook.MaxAttempts, cfg.Webhook.LeaseDuration)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, run := range append([]func(context.Context){relay.Run, webhookWorker.Run}, publisherWorkers...) {
+		workers.Add(1)
+		go func(run func(context.Context)) {
+			defer workers.Done()
+			run(workersCtx)
+		}(run)
+	}
 
-	ticketService := service.NewTicketService(ticketRepository, conn)
+	ticketBroker := broker.New(cfg.Stream.HistorySize, cfg.Stream.SubscriberBuffer)
+
+	ticketService := service.NewTicketService(ticketRepository, userDirectory, ticketPolicy, ticketWorkflow,
+		cfg.Ticket.Retention, ticketBroker)
+	commentService := service.NewCommentService(commentRepository, ticketRepository, userDirectory)
+	webhookService := service.NewWebhookService(webhookRepository, userDirectory)
 
 	tickerController := controller.NewTicketController(ticketService, validate)
+	workflowController := controller.NewWorkflowController(ticketWorkflow)
+	commentController := controller.NewCommentController(commentService, validate)
+	webhookController := controller.NewWebhookController(webhookService, validate)
+	streamController := controller.NewStreamController(ticketBroker, validate)
+
+	readiness := health.NewChecker(cfg.Health.Timeout)
+	readiness.Register("database", health.PoolCheck(db))
+	// Ticket reads degrade without the user service instead of failing, so it
+	// is reported but doesn't take the instance out of rotation.
+	readiness.RegisterOptional("user_service", health.ConnCheck(conn))
+	healthController := controller.NewHealthController(readiness)
+
+	ticketGrpcController := controller.NewTicketGrpcController(ticketService, validate)
+
+	grpcOptions, err := cfg.GRPC.ServerOptions()
+	if err != nil {
+		fatal("Invalid gRPC TLS configuration", "error", err)
+	}
+	grpcOptions = append(grpcOptions, grpc.ChainUnaryInterceptor(
+		middleware.GrpcRequestIdInterceptor,
+		middleware.GrpcErrorInterceptor,
+		middleware.GrpcAuthInterceptor(jwtVerifier),
+	))
+	grpcServer := grpc.NewServer(grpcOptions...)
+	grpcserver.RegisterTicketServiceServer(grpcServer, ticketGrpcController)
+
+	listener, err := net.Listen("tcp", cfg.GRPC.Addr)
+	if err != nil {
+		fatal("Could not listen", "addr", cfg.GRPC.Addr, "error", err)
+	}
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+		}
+	}()
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Use(middleware.RequestId())
+	app.Use(tracing.Middleware())
+	app.Use(metrics.NewHTTPMetrics(registry).Middleware())
+	app.Use(middleware.Timeout(cfg.HTTP.RequestTimeout))
 
 	app.Get("/", func(ctx *fiber.Ctx) error {
 		return ctx.SendString("Hello, World!")
 	})
+	app.Get("/healthz", healthController.Liveness)
+	app.Get("/readyz", healthController.Readiness)
 
 	api := app.Group("/api")
-	v1 := api.Group("/v1", middleware.Middleware)
+	v1 := api.Group("/v1", middleware.Middleware(jwtVerifier))
 	v1.Get("/tickets", tickerController.GetAllTicket)
 	v1.Post("/tickets/create", tickerController.CreateTicket)
+	v1.Post("/tickets/purge", tickerController.PurgeDeletedTickets)
+	v1.Get("/tickets/stream", streamController.StreamTickets)
 
 	v1.Get("/tickets/:ticketId/", tickerController.GetDetailTicket)
 	v1.Put("/tickets/:ticketId/assignee", tickerController.UpdateUserTicket)
 	v1.Put("/tickets/:ticketId/edit", tickerController.UpdateEditTicket)
 	v1.Put("/tickets/:ticketId/status", tickerController.UpdateStatusTicket)
+	v1.Delete("/tickets/:ticketId", tickerController.DeleteTicket)
+	v1.Post("/tickets/:ticketId/restore", tickerController.RestoreTicket)
+
+	v1.Post("/tickets/:ticketId/comments", commentController.CreateComment)
+	v1.Get("/tickets/:ticketId/comments", commentController.GetComments)
+	v1.Put("/tickets/:ticketId/comments/:commentId", commentController.UpdateComment)
+	v1.Delete("/tickets/:ticketId/comments/:commentId", commentController.DeleteComment)
 
 	v1.Get("/summary", tickerController.Summary)
 	v1.Get("/performance", tickerController.Performance)
 
-	app.Listen(":3001")
+	v1.Get("/workflow", workflowController.GetWorkflow)
+
+	v1.Get("/webhooks", webhookController.GetWebhooks)
+	v1.Post("/webhooks", webhookController.CreateWebhook)
+	v1.Get("/webhooks/:webhookId", webhookController.GetWebhook)
+	v1.Put("/webhooks/:webhookId", webhookController.UpdateWebhook)
+	v1.Delete("/webhooks/:webhookId", webhookController.DeleteWebhook)
+	v1.Get("/webhooks/:webhookId/deliveries", webhookController.GetDeliveries)
+	v1.Post("/webhooks/:webhookId/deliveries/:deliveryId/redeliver", webhookController.Redeliver)
+
+	metricsApp := fiber.New(fiber.Config{DisableStartupMessage: true})
+	metricsApp.Get("/metrics", metrics.Handler(registry))
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	serverErr := make(chan error, 2)
+	go func() {
+		if cfg.HTTP.TLS.Enabled() {
+			serverErr <- app.ListenTLS(cfg.HTTP.Addr, cfg.HTTP.TLS.CertFile, cfg.HTTP.TLS.KeyFile)
+		} else {
+			serverErr <- app.Listen(cfg.HTTP.Addr)
+		}
+	}()
+	go func() {
+		if err := metricsApp.Listen(cfg.Metrics.Addr); err != nil {
+			serverErr <- fmt.Errorf("metrics: %w", err)
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case <-signalCtx.Done():
+		slog.Info("Received shutdown signal", "timeout", cfg.ShutdownTimeout.String())
+	case err := <-serverErr:
+		slog.Error("HTTP server stopped", "error", err)
+		exitCode = 1
+	}
+	stopSignals()
+	readiness.Drain()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+
+	var shutdownErrs []error
+
+	// SSE streams never finish on their own, so end them before draining.
+	ticketBroker.Close()
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		shutdownErrs = append(shutdownErrs, fmt.Errorf("http server: %w", err))
+	}
+	if err := metricsApp.ShutdownWithContext(shutdownCtx); err != nil {
+		shutdownErrs = append(shutdownErrs, fmt.Errorf("metrics server: %w", err))
+	}
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+		shutdownErrs = append(shutdownErrs, errors.New("grpc server: forced stop after deadline"))
+	}
+
+	stopWorkers()
+	workersStopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersStopped)
+	}()
+	select {
+	case <-workersStopped:
+	case <-shutdownCtx.Done():
+		shutdownErrs = append(shutdownErrs, errors.New("background workers: still running after deadline"))
+	}
+
+	if err := conn.Close(); err != nil {
+		shutdownErrs = append(shutdownErrs, fmt.Errorf("user service connection: %w", err))
+	}
+	db.Close()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		shutdownErrs = append(shutdownErrs, fmt.Errorf("tracing: %w", err))
+	}
+	cancel()
+
+	if err := errors.Join(shutdownErrs...); err != nil {
+		slog.Error("Shutdown finished with errors", "error", err)
+		exitCode = 1
+	} else {
+		slog.Info("Shutdown complete")
+	}
+	os.Exit(exitCode)
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newEventPublisher builds the relay's publisher together with any workers it
+// needs running, such as the consumer draining the memory publisher.
+func newEventPublisher(cfg config.OutboxConfig, dispatcher outbox.EventPublisher) (outbox.EventPublisher, []func(context.Context)) {
+	switch cfg.Publisher {
+	case "webhook":
+		return outbox.MultiPublisher{dispatcher, outbox.NewWebhookPublisher(cfg.WebhookURL, 10*time.Second)}, nil
+	case "memory":
+		memory := outbox.NewMemoryPublisher(cfg.MemoryBuffer)
+		drain := func(ctx context.Context) {
+			memory.Drain(ctx, func(ctx context.Context, event model.Event) {
+				slog.InfoContext(ctx, "Outbox event published", "event_id", event.Id, "event_type", event.Type,
+					"ticket_id", event.TicketId)
+			})
+		}
+		return outbox.MultiPublisher{dispatcher, memory}, []func(context.Context){drain}
+	default:
+		return dispatcher, nil
+	}
+}
+
+func runMigrate(db *pgxpool.Pool, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migration.Up(context.Background(), db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return migration.Down(context.Background(), db, steps)
+	case "status":
+		statuses, err := migration.List(context.Background(), db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
 }
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package middleware
+
+import "net"
+
+// connClosed can't detect a disconnect on this platform.
+func connClosed(conn net.Conn) bool {
+	return false
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	problemContentType = "application/problem+json"
+
+	// statusClientClosedRequest is nginx's non-standard status for a request
+	// the client gave up on. The client never reads it; it keeps such requests
+	// out of the 5xx logs.
+	statusClientClosedRequest = 499
+)
+
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+var kindStatus = map[apperror.Kind]int{
+	apperror.KindInvalidArgument:      fiber.StatusBadRequest,
+	apperror.KindUnauthenticated:      fiber.StatusUnauthorized,
+	apperror.KindForbidden:            fiber.StatusForbidden,
+	apperror.KindNotFound:             fiber.StatusNotFound,
+	apperror.KindConflict:             fiber.StatusConflict,
+	apperror.KindPreconditionFailed:   fiber.StatusPreconditionFailed,
+	apperror.KindPreconditionRequired: fiber.StatusPreconditionRequired,
+	apperror.KindUnprocessable:        fiber.StatusUnprocessableEntity,
+	apperror.KindUpstream:             fiber.StatusBadGateway,
+	apperror.KindDeadlineExceeded:     fiber.StatusGatewayTimeout,
+	apperror.KindCanceled:             statusClientClosedRequest,
+	apperror.KindInternal:             fiber.StatusInternalServerError,
+}
+
+// ErrorHandler renders every error returned by a handler as an RFC 7807
+// problem document. Errors that aren't an *apperror.Error or *fiber.Error
+// are logged and reported as a bare 500.
+func ErrorHandler(ctx *fiber.Ctx, err error) error {
+	problem := Problem{
+		Type:     "about:blank",
+		Instance: ctx.OriginalURL(),
+	}
+
+	err = apperror.FromContext(err)
+
+	var appErr *apperror.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &appErr):
+		problem.Status = kindStatus[appErr.Kind]
+		problem.Code = string(appErr.Kind)
+		problem.Detail = appErr.Message
+		if appErr.Kind == apperror.KindInvalidArgument && appErr.Err != nil {
+			problem.Detail = appErr.Error()
+		}
+	case errors.As(err, &fiberErr):
+		problem.Status = fiberErr.Code
+		problem.Code = string(apperror.KindInternal)
+		if fiberErr.Code < fiber.StatusInternalServerError {
+			problem.Code = string(apperror.KindInvalidArgument)
+		}
+		if fiberErr.Code == fiber.StatusNotFound {
+			problem.Code = string(apperror.KindNotFound)
+		}
+		problem.Detail = fiberErr.Message
+	default:
+		problem.Status = fiber.StatusInternalServerError
+		problem.Code = string(apperror.KindInternal)
+	}
+
+	if problem.Status == 0 {
+		problem.Status = fiber.StatusInternalServerError
+	}
+	if problem.Status >= fiber.StatusInternalServerError {
+		slog.ErrorContext(ctx.UserContext(), "Request failed", "method", ctx.Method(), "path", ctx.OriginalURL(),
+			"status", problem.Status, "error", err)
+	}
+	problem.Title = http.StatusText(problem.Status)
+	if problem.Status == statusClientClosedRequest {
+		problem.Title = "Client Closed Request"
+	}
+
+	ctx.Status(problem.Status)
+	if err := ctx.JSON(problem); err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderContentType, problemContentType)
+
+	return nil
+}
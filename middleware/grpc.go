@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
+	"github.com/gemm123/vkrf-ticket/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type principalKey struct{}
+
+var kindCode = map[apperror.Kind]codes.Code{
+	apperror.KindInvalidArgument:      codes.InvalidArgument,
+	apperror.KindUnauthenticated:      codes.Unauthenticated,
+	apperror.KindForbidden:            codes.PermissionDenied,
+	apperror.KindNotFound:             codes.NotFound,
+	apperror.KindConflict:             codes.AlreadyExists,
+	apperror.KindPreconditionFailed:   codes.Aborted,
+	apperror.KindPreconditionRequired: codes.FailedPrecondition,
+	apperror.KindUnprocessable:        codes.FailedPrecondition,
+	apperror.KindUpstream:             codes.Unavailable,
+	apperror.KindDeadlineExceeded:     codes.DeadlineExceeded,
+	apperror.KindCanceled:             codes.Canceled,
+	apperror.KindInternal:             codes.Internal,
+}
+
+func PrincipalFromContext(ctx context.Context) (model.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(model.Principal)
+	return principal, ok
+}
+
+// GrpcAuthInterceptor verifies the bearer token sent in the "authorization"
+// metadata, the gRPC counterpart of Middleware.
+func GrpcAuthInterceptor(verifier *helper.JWTVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var header string
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+
+		token, ok := bearerToken(header)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, helper.ErrTokenMissing.Error())
+		}
+
+		principal, err := verifier.Verify(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return handler(context.WithValue(ctx, principalKey{}, principal), req)
+	}
+}
+
+// GrpcErrorInterceptor translates domain errors into gRPC status codes the
+// same way ErrorHandler translates them into HTTP statuses.
+func GrpcErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	err = apperror.FromContext(err)
+
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		slog.ErrorContext(ctx, "gRPC request failed", "method", info.FullMethod, "error", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	message := appErr.Message
+	if appErr.Kind == apperror.KindInvalidArgument {
+		message = appErr.Error()
+	}
+	if appErr.Kind == apperror.KindInternal || appErr.Kind == apperror.KindUpstream {
+		slog.ErrorContext(ctx, "gRPC request failed", "method", info.FullMethod, "error", err)
+	}
+
+	return nil, status.Error(kindCode[appErr.Kind], message)
+}
@@ -1,13 +1,51 @@
 package middleware
 
 import (
+	"errors"
+	"strings"
+
+	"github.com/gemm123/vkrf-ticket/helper"
+	"github.com/gemm123/vkrf-ticket/internal/apperror"
 	"github.com/gofiber/fiber/v2"
 )
 
-func Middleware(ctx *fiber.Ctx) error {
-	email := ctx.Get("Authorization")
+func Middleware(verifier *helper.JWTVerifier) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token, ok := bearerToken(ctx.Get(fiber.HeaderAuthorization))
+		if !ok {
+			return unauthorized(ctx, helper.ErrTokenMissing)
+		}
+
+		principal, err := verifier.Verify(token)
+		if err != nil {
+			return unauthorized(ctx, err)
+		}
+
+		ctx.Locals("principal", principal)
+
+		return ctx.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
 
-	ctx.Locals("email", email)
+func unauthorized(ctx *fiber.Ctx, err error) error {
+	message := "Invalid token"
+	switch {
+	case errors.Is(err, helper.ErrTokenMissing):
+		message = "Missing token"
+	case errors.Is(err, helper.ErrTokenExpired):
+		message = "Token expired"
+	}
 
-	return ctx.Next()
+	ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="vkrf-ticket"`)
+	return apperror.Unauthenticated(message, err)
 }
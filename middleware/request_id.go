@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gemm123/vkrf-ticket/internal/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const maxRequestIdLength = 128
+
+// RequestId keeps the caller's X-Request-ID, or generates one, echoes it in
+// the response and stores it in the user context for logging and for the
+// user service calls.
+func RequestId() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		requestId := ctx.Get(logging.RequestIdHeader)
+		if !validRequestId(requestId) {
+			requestId = uuid.NewString()
+		}
+
+		ctx.Set(logging.RequestIdHeader, requestId)
+		ctx.SetUserContext(logging.WithRequestId(ctx.UserContext(), requestId))
+
+		return ctx.Next()
+	}
+}
+
+// GrpcRequestIdInterceptor is the gRPC counterpart of RequestId, reading the
+// "x-request-id" metadata.
+func GrpcRequestIdInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var requestId string
+	if values := md.Get(logging.RequestIdMetadata); len(values) > 0 {
+		requestId = values[0]
+	}
+	if !validRequestId(requestId) {
+		requestId = uuid.NewString()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIdMetadata, requestId))
+
+	return handler(logging.WithRequestId(ctx, requestId), req)
+}
+
+// validRequestId rejects ids that are empty, overly long or contain anything
+// but printable ASCII, since they end up in logs and outgoing headers.
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] < 0x21 || requestId[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"time"
+)
+
+// disconnectPollInterval is how often a running request checks whether its
+// client has gone away.
+const disconnectPollInterval = 250 * time.Millisecond
+
+// Timeout gives every request a context that handlers pass down to the
+// service, repository and user service calls. It is cancelled when the
+// timeout elapses, the client disconnects or the server shuts down, and once
+// the handler returns.
+//
+// fasthttp doesn't report a client disconnect while a handler is running, so
+// the connection is polled for it instead. Where that isn't possible (TLS or
+// platforms without connClosed support) an aborted request keeps its context
+// until the timeout.
+func Timeout(timeout time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		reqCtx, cancel := context.WithTimeout(ctx.UserContext(), timeout)
+		defer cancel()
+
+		stop := context.AfterFunc(ctx.Context(), cancel)
+		defer stop()
+
+		if conn := ctx.Context().Conn(); conn != nil {
+			go cancelOnDisconnect(reqCtx, conn, cancel)
+		}
+
+		ctx.SetUserContext(reqCtx)
+		return ctx.Next()
+	}
+}
+
+func cancelOnDisconnect(ctx context.Context, conn net.Conn, cancel context.CancelFunc) {
+	ticker := time.NewTicker(disconnectPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if connClosed(conn) {
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serveWaiting starts an app whose handler waits up to wait for the request
+// context to end and reports its error, nil if it outlived the wait.
+func serveWaiting(t *testing.T, wait time.Duration) (string, <-chan error) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(Timeout(time.Minute))
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		select {
+		case <-ctx.UserContext().Done():
+			done <- ctx.UserContext().Err()
+		case <-time.After(wait):
+			done <- nil
+		}
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	return ln.Addr().String(), done
+}
+
+func sendRequest(t *testing.T, addr string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestTimeoutCancelsOnClientDisconnect(t *testing.T) {
+	addr, done := serveWaiting(t, 5*time.Second)
+
+	conn := sendRequest(t, addr)
+	time.Sleep(100 * time.Millisecond)
+	conn.Close()
+
+	if err := <-done; err == nil {
+		t.Fatal("request context outlived the client connection")
+	}
+}
+
+func TestTimeoutKeepsConnectedRequest(t *testing.T) {
+	addr, done := serveWaiting(t, 3*disconnectPollInterval)
+
+	conn := sendRequest(t, addr)
+	defer conn.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("request context ended with %v while the client was connected", err)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS history_ticket;
+DROP TABLE IF EXISTS tickets;
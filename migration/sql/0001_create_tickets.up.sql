@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS tickets (
+    id          uuid PRIMARY KEY,
+    user_id     uuid        NOT NULL,
+    title       varchar     NOT NULL,
+    description varchar     NOT NULL,
+    status      varchar     NOT NULL,
+    point       int         NOT NULL,
+    created_at  timestamptz NOT NULL,
+    updated_at  timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS history_ticket (
+    id         uuid PRIMARY KEY,
+    ticket_id  uuid        NOT NULL REFERENCES tickets (id),
+    date       varchar     NOT NULL,
+    title      varchar     NOT NULL,
+    "user"     varchar     NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS history_ticket_ticket_id_idx ON history_ticket (ticket_id);
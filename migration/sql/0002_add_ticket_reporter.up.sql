@@ -0,0 +1,5 @@
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS reporter_id uuid;
+
+UPDATE tickets SET reporter_id = user_id WHERE reporter_id IS NULL;
+
+ALTER TABLE tickets ALTER COLUMN reporter_id SET NOT NULL;
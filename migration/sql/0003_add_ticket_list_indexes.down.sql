@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS tickets_user_id_status_idx;
+DROP INDEX IF EXISTS tickets_updated_at_id_idx;
+DROP INDEX IF EXISTS tickets_created_at_id_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS tickets_created_at_id_idx ON tickets (created_at, id);
+CREATE INDEX IF NOT EXISTS tickets_updated_at_id_idx ON tickets (updated_at, id);
+CREATE INDEX IF NOT EXISTS tickets_user_id_status_idx ON tickets (user_id, status);
@@ -0,0 +1,28 @@
+DROP INDEX IF EXISTS history_ticket_ticket_id_created_at_idx;
+
+ALTER TABLE history_ticket
+    ADD COLUMN date       varchar,
+    ADD COLUMN title      varchar,
+    ADD COLUMN updated_at timestamptz;
+
+UPDATE history_ticket
+SET date       = to_char(created_at, 'DD Mon YYYY'),
+    updated_at = created_at,
+    title      = CASE event_type
+        WHEN 'created' THEN 'Ticket Created'
+        WHEN 'assignee_changed' THEN 'Change Assignees to ' || coalesce(new_value ->> 'name', '')
+        WHEN 'edited' THEN 'Edited by ' || actor_name
+        WHEN 'status_changed' THEN actor_name || ' Change status to ' || coalesce(new_value ->> 'status', '')
+        ELSE coalesce(new_value ->> 'title', event_type)
+    END;
+
+ALTER TABLE history_ticket RENAME COLUMN actor_name TO "user";
+
+ALTER TABLE history_ticket
+    ALTER COLUMN date SET NOT NULL,
+    ALTER COLUMN title SET NOT NULL,
+    ALTER COLUMN updated_at SET NOT NULL,
+    DROP COLUMN event_type,
+    DROP COLUMN actor_id,
+    DROP COLUMN old_value,
+    DROP COLUMN new_value;
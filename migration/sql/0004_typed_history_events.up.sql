@@ -0,0 +1,50 @@
+ALTER TABLE history_ticket
+    ADD COLUMN IF NOT EXISTS event_type varchar,
+    ADD COLUMN IF NOT EXISTS actor_id   uuid,
+    ADD COLUMN IF NOT EXISTS old_value  jsonb,
+    ADD COLUMN IF NOT EXISTS new_value  jsonb;
+
+-- The legacy columns are gone once this has run, so the rename and the
+-- backfill from titles only happen the first time.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'history_ticket' AND column_name = 'user') THEN
+        ALTER TABLE history_ticket RENAME COLUMN "user" TO actor_name;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'history_ticket' AND column_name = 'title') THEN
+        UPDATE history_ticket
+        SET event_type = 'created'
+        WHERE event_type IS NULL AND title = 'Ticket Created';
+
+        UPDATE history_ticket
+        SET event_type = 'assignee_changed',
+            new_value  = jsonb_build_object('name', substring(title FROM '^Change Assignees to (.*)$'))
+        WHERE event_type IS NULL AND title LIKE 'Change Assignees to %';
+
+        UPDATE history_ticket
+        SET event_type = 'edited'
+        WHERE event_type IS NULL AND title LIKE 'Edited by %';
+
+        UPDATE history_ticket
+        SET event_type = 'status_changed',
+            new_value  = jsonb_build_object('status', substring(title FROM ' Change status to (.*)$'))
+        WHERE event_type IS NULL AND title LIKE '% Change status to %';
+
+        UPDATE history_ticket
+        SET event_type = 'legacy',
+            new_value  = jsonb_build_object('title', title)
+        WHERE event_type IS NULL;
+    END IF;
+END
+$$;
+
+ALTER TABLE history_ticket
+    ALTER COLUMN event_type SET NOT NULL,
+    DROP COLUMN IF EXISTS date,
+    DROP COLUMN IF EXISTS title,
+    DROP COLUMN IF EXISTS updated_at;
+
+CREATE INDEX IF NOT EXISTS history_ticket_ticket_id_created_at_idx ON history_ticket (ticket_id, created_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id         uuid PRIMARY KEY,
+    ticket_id  uuid        NOT NULL REFERENCES tickets (id),
+    author_id  uuid        NOT NULL,
+    body       text        NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS comments_ticket_id_created_at_id_idx ON comments (ticket_id, created_at, id);
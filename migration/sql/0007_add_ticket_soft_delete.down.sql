@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS tickets_deleted_at_idx;
+
+ALTER TABLE tickets DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id              uuid PRIMARY KEY,
+    event_type      varchar     NOT NULL,
+    ticket_id       uuid        NOT NULL,
+    payload         jsonb       NOT NULL,
+    occurred_at     timestamptz NOT NULL,
+    attempts        int         NOT NULL DEFAULT 0,
+    last_error      text,
+    next_attempt_at timestamptz NOT NULL,
+    published_at    timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE published_at IS NULL;
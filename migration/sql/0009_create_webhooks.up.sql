@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id          uuid PRIMARY KEY,
+    url         varchar     NOT NULL,
+    secret      varchar     NOT NULL,
+    event_types text[]      NOT NULL DEFAULT '{}',
+    active      boolean     NOT NULL DEFAULT true,
+    created_by  uuid        NOT NULL,
+    created_at  timestamptz NOT NULL,
+    updated_at  timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              uuid PRIMARY KEY,
+    webhook_id      uuid        NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        uuid        NOT NULL,
+    event_type      varchar     NOT NULL,
+    payload         jsonb       NOT NULL,
+    status          varchar     NOT NULL,
+    attempts        int         NOT NULL DEFAULT 0,
+    response_status int,
+    last_error      text,
+    redelivery_of   uuid,
+    next_attempt_at timestamptz NOT NULL,
+    created_at      timestamptz NOT NULL,
+    delivered_at    timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx
+    ON webhook_deliveries (webhook_id, event_id) WHERE redelivery_of IS NULL;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx
+    ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx
+    ON webhook_deliveries (webhook_id, created_at DESC);
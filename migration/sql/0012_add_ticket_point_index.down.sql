@@ -0,0 +1 @@
+DROP INDEX IF EXISTS tickets_point_id_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS tickets_point_id_idx ON tickets (point, id);